@@ -0,0 +1,671 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// The binary format starts with a version byte and a flags byte, followed by
+// an error record. Strings and byte slices are prefixed with their length as
+// a uvarint, and lists are prefixed with their element count as a uvarint.
+//
+// An error record is a kind byte, the class path and:
+//   - for recordSystem, the message and an optional cause record
+//   - for recordError, the cause record, data, and if flagFrames is set, the
+//     stacks and exits
+//   - for recordGroup, the excess count (uvarint), a list of records for the
+//     errors of a finalized ErrorGroup and a list of (class path, count
+//     (uvarint)) pairs if the errors were counted by class
+//
+// An optional record is a byte that is 1 if the record follows, 0 otherwise.
+// Data is a list of (name, value) pairs, where a value is a tag byte followed
+// by the payload for that tag. A list of stacks is a list of lists of frames,
+// and a frame is the function, file, line (uvarint) and package.
+
+const (
+	binaryVersion = 1
+
+	flagFrames = 1 << 0
+
+	recordSystem = 0
+	recordError  = 1
+	recordGroup  = 2
+
+	tagNil    = 0
+	tagBool   = 1
+	tagInt    = 2
+	tagUint   = 3
+	tagFloat  = 4
+	tagString = 5
+	tagBytes  = 6
+	tagJSON   = 7
+
+	// maxBinaryDepth limits how deeply wrapped errors can be nested when
+	// decoding, so that malicious input can't exhaust the stack.
+	maxBinaryDepth = 1024
+)
+
+// BinaryError is the class of errors returned when decoding malformed binary
+// encoded errors.
+var BinaryError = NewClass("Binary Error", NoCaptureStack())
+
+func errTruncated() error {
+	return BinaryError.NewWith("truncated input")
+}
+
+func errMismatch() error {
+	return BinaryError.NewWith("data value does not match its type")
+}
+
+type binaryEncoder struct {
+	buf    []byte
+	frames bool
+}
+
+func (b *binaryEncoder) uvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	b.buf = append(b.buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func (b *binaryEncoder) varint(v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	b.buf = append(b.buf, tmp[:binary.PutVarint(tmp[:], v)]...)
+}
+
+func (b *binaryEncoder) string(s string) {
+	b.uvarint(uint64(len(s)))
+	b.buf = append(b.buf, s...)
+}
+
+func (b *binaryEncoder) frame(f Frame) {
+	b.string(f.Function)
+	b.string(f.File)
+	b.uvarint(uint64(f.Line))
+	b.string(f.Package)
+}
+
+func (b *binaryEncoder) value(val interface{}) error {
+	if val == nil {
+		b.buf = append(b.buf, tagNil)
+		return nil
+	}
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Bool:
+		b.buf = append(b.buf, tagBool)
+		if rv.Bool() {
+			b.buf = append(b.buf, 1)
+		} else {
+			b.buf = append(b.buf, 0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		b.buf = append(b.buf, tagInt)
+		b.varint(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		b.buf = append(b.buf, tagUint)
+		b.uvarint(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		var tmp [8]byte
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(rv.Float()))
+		b.buf = append(append(b.buf, tagFloat), tmp[:]...)
+	case reflect.String:
+		b.buf = append(b.buf, tagString)
+		b.string(rv.String())
+	default:
+		if rv.Kind() == reflect.Slice &&
+			rv.Type().Elem().Kind() == reflect.Uint8 {
+			b.buf = append(b.buf, tagBytes)
+			b.string(string(rv.Bytes()))
+			return nil
+		}
+		raw, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		b.buf = append(b.buf, tagJSON)
+		b.string(string(raw))
+	}
+	return nil
+}
+
+func (b *binaryEncoder) optional(err error) error {
+	if err == nil {
+		b.buf = append(b.buf, 0)
+		return nil
+	}
+	b.buf = append(b.buf, 1)
+	return b.record(err)
+}
+
+func (b *binaryEncoder) record(err error) error {
+	if group, ok := err.(*groupErrors); ok {
+		b.buf = append(b.buf, recordGroup)
+		b.string(SystemError.path)
+		b.uvarint(uint64(group.excess))
+		b.uvarint(uint64(len(group.errs)))
+		for _, child := range group.errs {
+			if err := b.record(child); err != nil {
+				return err
+			}
+		}
+		b.uvarint(uint64(len(group.counts)))
+		for _, count := range group.counts {
+			b.string(count.Class.path)
+			b.uvarint(uint64(count.Count))
+		}
+		return nil
+	}
+	cast, ok := err.(*Error)
+	if !ok {
+		b.buf = append(b.buf, recordSystem)
+		b.string(GetClass(err).path)
+		b.string(err.Error())
+		return b.optional(errors.Unwrap(err))
+	}
+
+	b.buf = append(b.buf, recordError)
+	b.string(cast.class.path)
+	if err := b.record(cast.err); err != nil {
+		return err
+	}
+
+	var keys []registeredKey
+	for key := range cast.data {
+		if rk, ok := lookupDataKey(key); ok {
+			keys = append(keys, rk)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].name < keys[j].name
+	})
+	b.uvarint(uint64(len(keys)))
+	for _, rk := range keys {
+		b.string(rk.name)
+		if err := b.value(cast.data[rk.key]); err != nil {
+			return err
+		}
+	}
+
+	if !b.frames {
+		return nil
+	}
+	stacks := cast.Frames()
+	b.uvarint(uint64(len(stacks)))
+	for _, stack := range stacks {
+		b.uvarint(uint64(len(stack)))
+		for _, f := range stack {
+			b.frame(f)
+		}
+	}
+	exits := cast.ExitFrames()
+	b.uvarint(uint64(len(exits)))
+	for _, f := range exits {
+		b.frame(f)
+	}
+	return nil
+}
+
+// EncodeBinary encodes err in the format used by MarshalBinary, which
+// includes the class path of err and every error it wraps, their messages,
+// and data stored with keys registered with RegisterDataKey. Stacks and exits
+// are only included if frames is true. err may be any non-nil error.
+func EncodeBinary(err error, frames bool) ([]byte, error) {
+	b := binaryEncoder{buf: []byte{binaryVersion, 0}, frames: frames}
+	if frames {
+		b.buf[1] |= flagFrames
+	}
+	if err := b.record(err); err != nil {
+		return nil, err
+	}
+	return b.buf, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. It is the same as
+// EncodeBinary(e, true).
+func (e *Error) MarshalBinary() ([]byte, error) {
+	return EncodeBinary(e, true)
+}
+
+type binaryDecoder struct {
+	data   []byte
+	frames bool
+	depth  int
+}
+
+func (d *binaryDecoder) byte() (byte, error) {
+	if len(d.data) < 1 {
+		return 0, errTruncated()
+	}
+	v := d.data[0]
+	d.data = d.data[1:]
+	return v, nil
+}
+
+func (d *binaryDecoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, BinaryError.NewWith("invalid uvarint")
+	}
+	d.data = d.data[n:]
+	return v, nil
+}
+
+func (d *binaryDecoder) varint() (int64, error) {
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		return 0, BinaryError.NewWith("invalid varint")
+	}
+	d.data = d.data[n:]
+	return v, nil
+}
+
+// count reads a list length, rejecting lengths that couldn't possibly fit in
+// the remaining input given each element takes at least min bytes.
+func (d *binaryDecoder) count(min int) (int, error) {
+	v, err := d.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if v > uint64(len(d.data)/min) {
+		return 0, errTruncated()
+	}
+	return int(v), nil
+}
+
+func (d *binaryDecoder) string() (string, error) {
+	n, err := d.count(1)
+	if err != nil {
+		return "", err
+	}
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	return s, nil
+}
+
+func (d *binaryDecoder) frame() (f Frame, err error) {
+	if f.Function, err = d.string(); err != nil {
+		return f, err
+	}
+	if f.File, err = d.string(); err != nil {
+		return f, err
+	}
+	line, err := d.uvarint()
+	if err != nil {
+		return f, err
+	}
+	if line > math.MaxInt32 {
+		return f, BinaryError.NewWith("invalid line number")
+	}
+	f.Line = int(line)
+	f.Package, err = d.string()
+	return f, err
+}
+
+func (d *binaryDecoder) frameList() ([]Frame, error) {
+	n, err := d.count(4)
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Frame, 0, n)
+	for i := 0; i < n; i++ {
+		f, err := d.frame()
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
+
+// value decodes a data value into a value of type typ.
+func (d *binaryDecoder) value(typ reflect.Type) (interface{}, error) {
+	tag, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	if tag == tagNil {
+		return nil, nil
+	}
+	rv := reflect.New(typ).Elem()
+	switch tag {
+	case tagBool:
+		v, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		if rv.Kind() != reflect.Bool || v > 1 {
+			return nil, errMismatch()
+		}
+		rv.SetBool(v == 1)
+	case tagInt:
+		v, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+			reflect.Int64:
+		default:
+			return nil, errMismatch()
+		}
+		if rv.OverflowInt(v) {
+			return nil, errMismatch()
+		}
+		rv.SetInt(v)
+	case tagUint:
+		v, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		switch rv.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+			reflect.Uint64, reflect.Uintptr:
+		default:
+			return nil, errMismatch()
+		}
+		if rv.OverflowUint(v) {
+			return nil, errMismatch()
+		}
+		rv.SetUint(v)
+	case tagFloat:
+		if len(d.data) < 8 {
+			return nil, errTruncated()
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.data))
+		d.data = d.data[8:]
+		if rv.Kind() != reflect.Float32 && rv.Kind() != reflect.Float64 {
+			return nil, errMismatch()
+		}
+		rv.SetFloat(v)
+	case tagString:
+		v, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		if rv.Kind() != reflect.String {
+			return nil, errMismatch()
+		}
+		rv.SetString(v)
+	case tagBytes:
+		v, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		if rv.Kind() != reflect.Slice ||
+			rv.Type().Elem().Kind() != reflect.Uint8 {
+			return nil, errMismatch()
+		}
+		rv.SetBytes([]byte(v))
+	case tagJSON:
+		v, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		ptr := reflect.New(typ)
+		if err := json.Unmarshal([]byte(v), ptr.Interface()); err != nil {
+			return nil, BinaryError.Wrap(err)
+		}
+		rv = ptr.Elem()
+	default:
+		return nil, BinaryError.New("unknown data tag %d", tag)
+	}
+	return rv.Interface(), nil
+}
+
+// skipValue skips a data value for a key that isn't registered.
+func (d *binaryDecoder) skipValue() error {
+	tag, err := d.byte()
+	if err != nil {
+		return err
+	}
+	switch tag {
+	case tagNil:
+	case tagBool:
+		_, err = d.byte()
+	case tagInt:
+		_, err = d.varint()
+	case tagUint:
+		_, err = d.uvarint()
+	case tagFloat:
+		if len(d.data) < 8 {
+			return errTruncated()
+		}
+		d.data = d.data[8:]
+	case tagString, tagBytes, tagJSON:
+		_, err = d.string()
+	default:
+		return BinaryError.New("unknown data tag %d", tag)
+	}
+	return err
+}
+
+func (d *binaryDecoder) optional() (error, error) {
+	present, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch present {
+	case 0:
+		return nil, nil
+	case 1:
+		return d.record()
+	default:
+		return nil, BinaryError.NewWith("invalid optional marker")
+	}
+}
+
+// record decodes an error record. The first return value is the decoded
+// error and the second is any problem with the input.
+func (d *binaryDecoder) record() (error, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxBinaryDepth {
+		return nil, BinaryError.NewWith("errors nested too deeply")
+	}
+
+	kind, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	path, err := d.string()
+	if err != nil {
+		return nil, err
+	}
+	class := resolveClass(path)
+
+	switch kind {
+	case recordSystem:
+		message, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		cause, err := d.optional()
+		if err != nil {
+			return nil, err
+		}
+		if !class.Is(SystemError) {
+			class = SystemError
+		}
+		return &remoteError{class: class, message: message, err: cause}, nil
+	case recordError:
+		return d.error(class)
+	case recordGroup:
+		return d.group()
+	default:
+		return nil, BinaryError.New("unknown record kind %d", kind)
+	}
+}
+
+// group decodes the remainder of a recordGroup record.
+func (d *binaryDecoder) group() (*groupErrors, error) {
+	excess, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if excess > math.MaxInt32 {
+		return nil, BinaryError.NewWith("invalid excess count")
+	}
+	n, err := d.count(3)
+	if err != nil {
+		return nil, err
+	}
+	group := &groupErrors{excess: int(excess)}
+	for i := 0; i < n; i++ {
+		child, err := d.record()
+		if err != nil {
+			return nil, err
+		}
+		group.errs = append(group.errs, child)
+	}
+	n, err = d.count(2)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		path, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		count, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if count > math.MaxInt32 {
+			return nil, BinaryError.NewWith("invalid class count")
+		}
+		group.counts = append(group.counts, ClassCount{
+			Class: resolveClass(path),
+			Count: int(count)})
+	}
+	if len(group.errs) == 0 && len(group.counts) == 0 {
+		return nil, BinaryError.NewWith("empty error group")
+	}
+	group.assignExemplars()
+	return group, nil
+}
+
+// error decodes the remainder of a recordError record.
+func (d *binaryDecoder) error(class *ErrorClass) (*Error, error) {
+	cause, err := d.record()
+	if err != nil {
+		return nil, err
+	}
+	rv := &Error{class: class, err: cause}
+
+	n, err := d.count(2)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		name, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		rk, ok := lookupDataKeyName(name)
+		if !ok {
+			if err := d.skipValue(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		val, err := d.value(rk.typ)
+		if err != nil {
+			return nil, err
+		}
+		if rv.data == nil {
+			rv.data = make(map[DataKey]interface{})
+		}
+		rv.data[rk.key] = val
+	}
+
+	if !d.frames {
+		return rv, nil
+	}
+	n, err = d.count(1)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		frames, err := d.frameList()
+		if err != nil {
+			return nil, err
+		}
+		rv.stacks = append(rv.stacks, decodedStack(frames))
+	}
+	exits, err := d.frameList()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range exits {
+		rv.exits = append(rv.exits, decodedStack([]Frame{f}))
+	}
+	return rv, nil
+}
+
+// DecodeBinary decodes an error encoded with EncodeBinary or MarshalBinary.
+// Classes are looked up the same way as UnmarshalJSON does. Errors that were
+// not generated through this package are reconstructed as errors with the
+// same message that GetClass and Contains still put in their original class.
+// decoded is the decoded error, and err reports malformed input.
+func DecodeBinary(data []byte) (decoded, err error) {
+	if len(data) < 2 {
+		return nil, errTruncated()
+	}
+	if data[0] != binaryVersion {
+		return nil, BinaryError.New("unsupported version %d", data[0])
+	}
+	if data[1]&^flagFrames != 0 {
+		return nil, BinaryError.New("unknown flags %#x", data[1])
+	}
+	d := binaryDecoder{data: data[2:], frames: data[1]&flagFrames != 0}
+	rv, err := d.record()
+	if err != nil {
+		return nil, err
+	}
+	if len(d.data) > 0 {
+		return nil, BinaryError.NewWith("trailing data")
+	}
+	return rv, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. If the encoded error
+// was not generated through this package, the receiver will be an error of
+// the class it was determined to be in, wrapping an error with its message.
+func (e *Error) UnmarshalBinary(data []byte) error {
+	decoded, err := DecodeBinary(data)
+	if err != nil {
+		return err
+	}
+	switch decoded := decoded.(type) {
+	case *Error:
+		*e = *decoded
+	case *remoteError:
+		*e = Error{class: decoded.class, err: &remoteError{
+			class:   SystemError,
+			message: decoded.message,
+			err:     decoded.err}}
+	default:
+		*e = Error{class: SystemError, err: decoded}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package errors
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+func FuzzDecodeBinary(f *testing.F) {
+	group := NewBoundedErrorGroup(2)
+	group.Add(jsonNotExist.New("missing"))
+	group.Add(io.EOF)
+	group.Add(io.EOF)
+	for _, err := range []error{
+		group.Finalize(),
+		Record(jsonNotExist.NewWith("missing", SetData(jsonCodeKey, 404))),
+		jsonRequest.Wrap(fmt.Errorf("reading: %w", io.EOF),
+			SetData(binaryFloatKey, float32(1.5)),
+			SetData(binaryListKey, []string{"a"})),
+		io.EOF,
+	} {
+		for _, frames := range []bool{false, true} {
+			data, eerr := EncodeBinary(err, frames)
+			if eerr != nil {
+				f.Fatal(eerr)
+			}
+			f.Add(data)
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoded, err := DecodeBinary(data)
+		if err != nil {
+			if !BinaryError.Contains(err) {
+				t.Fatalf("unexpected error class: %v", err)
+			}
+			return
+		}
+		// anything that decodes must encode again and decode the same way.
+		again, err := EncodeBinary(decoded, data[1]&flagFrames != 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		redecoded, err := DecodeBinary(again)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if redecoded.Error() != decoded.Error() {
+			t.Fatalf("%q != %q", redecoded.Error(), decoded.Error())
+		}
+	})
+}
@@ -0,0 +1,162 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+var (
+	binaryFloatKey = GenSym()
+	binaryListKey  = GenSym()
+)
+
+func init() {
+	RegisterDataKey("errors_test.float", binaryFloatKey, float32(0))
+	RegisterDataKey("errors_test.list", binaryListKey, []string(nil))
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	err := Record(jsonRequest.Wrap(jsonNotExist.Wrap(
+		fmt.Errorf("reading: %w", io.EOF)),
+		SetData(jsonCodeKey, 404),
+		SetData(binaryFloatKey, float32(1.5)),
+		SetData(binaryListKey, []string{"a", "b"}),
+		SetData(jsonUnknownKey, "dropped")))
+	data, merr := err.(*Error).MarshalBinary()
+	if merr != nil {
+		t.Fatalf("unexpected error: %v", merr)
+	}
+	var decoded Error
+	if uerr := decoded.UnmarshalBinary(data); uerr != nil {
+		t.Fatalf("unexpected error: %v", uerr)
+	}
+
+	assert(t, decoded.Class() == jsonRequest)
+	assert(t, decoded.Error() == err.Error())
+	assert(t, jsonNotExist.Contains(&decoded, IncludeWrapped))
+	assert(t, EOF.Contains(&decoded, IncludeWrapped))
+	assert(t, GetData(&decoded, jsonCodeKey).(int) == 404)
+	assert(t, GetData(&decoded, binaryFloatKey).(float32) == 1.5)
+	assert(t, fmt.Sprint(GetData(&decoded, binaryListKey)) == "[a b]")
+	assert(t, GetData(&decoded, jsonUnknownKey) == nil)
+	assert(t, GetExits(&decoded) == GetExits(err))
+
+	again, merr := decoded.MarshalBinary()
+	if merr != nil {
+		t.Fatalf("unexpected error: %v", merr)
+	}
+	assert(t, string(again) == string(data))
+}
+
+func TestBinaryNoFrames(t *testing.T) {
+	err := Record(jsonNotExist.New("missing"))
+	data, eerr := EncodeBinary(err, false)
+	if eerr != nil {
+		t.Fatalf("unexpected error: %v", eerr)
+	}
+	full, _ := EncodeBinary(err, true)
+	assert(t, len(data) < len(full))
+
+	decoded, derr := DecodeBinary(data)
+	if derr != nil {
+		t.Fatalf("unexpected error: %v", derr)
+	}
+	assert(t, jsonNotExist.Contains(decoded))
+	assert(t, GetMessage(decoded) == "Not Exist: missing")
+	assert(t, GetStack(decoded) == "")
+	assert(t, GetExits(decoded) == "")
+}
+
+func TestBinarySystemError(t *testing.T) {
+	data, eerr := EncodeBinary(io.ErrUnexpectedEOF, true)
+	if eerr != nil {
+		t.Fatalf("unexpected error: %v", eerr)
+	}
+	decoded, derr := DecodeBinary(data)
+	if derr != nil {
+		t.Fatalf("unexpected error: %v", derr)
+	}
+	assert(t, GetClass(decoded) == UnexpectedEOFError)
+	assert(t, decoded.Error() == io.ErrUnexpectedEOF.Error())
+}
+
+func TestBinaryMalformed(t *testing.T) {
+	data, _ := EncodeBinary(Record(jsonNotExist.NewWith("missing",
+		SetData(jsonCodeKey, 404))), true)
+	for i := 0; i < len(data); i++ {
+		_, err := DecodeBinary(data[:i])
+		assert(t, BinaryError.Contains(err))
+	}
+	_, err := DecodeBinary(append(data, 0))
+	assert(t, BinaryError.Contains(err))
+	_, err = DecodeBinary(append([]byte{2}, data[1:]...))
+	assert(t, BinaryError.Contains(err))
+
+	// a data value of the wrong type
+	bad := []byte{binaryVersion, 0, recordError, 0,
+		recordSystem, 0, 0, 0,
+		1, 16}
+	bad = append(bad, "errors_test.code"...)
+	bad = append(bad, tagString, 1, 'x')
+	_, err = DecodeBinary(bad)
+	assert(t, BinaryError.Contains(err))
+}
+
+func TestBinaryGroup(t *testing.T) {
+	errs := NewBoundedErrorGroup(2)
+	errs.Add(jsonNotExist.New("missing"))
+	errs.Add(io.EOF)
+	errs.Add(io.EOF)
+	err := errs.Finalize()
+	data, merr := err.(*Error).MarshalBinary()
+	if merr != nil {
+		t.Fatalf("unexpected error: %v", merr)
+	}
+	var decoded Error
+	if uerr := decoded.UnmarshalBinary(data); uerr != nil {
+		t.Fatalf("unexpected error: %v", uerr)
+	}
+
+	assert(t, decoded.Class() == ErrorGroupError)
+	assert(t, decoded.Error() == err.Error())
+	assert(t, jsonNotExist.Contains(&decoded, IncludeWrapped))
+	assert(t, EOF.Contains(&decoded, IncludeWrapped))
+	assert(t, len(GetGroupErrors(&decoded)) == 2)
+}
+
+func TestBinarySummarizingGroup(t *testing.T) {
+	errs := NewSummarizingErrorGroup(1)
+	errs.Add(jsonNotExist.New("missing"))
+	errs.Add(jsonNotExist.New("missing"))
+	errs.Add(io.EOF)
+	err := errs.Finalize()
+	data, eerr := EncodeBinary(err, false)
+	if eerr != nil {
+		t.Fatalf("unexpected error: %v", eerr)
+	}
+	decoded, derr := DecodeBinary(data)
+	if derr != nil {
+		t.Fatalf("unexpected error: %v", derr)
+	}
+
+	assert(t, GetMessage(decoded) == GetMessage(err))
+	counts := GetClassCounts(decoded)
+	assert(t, len(counts) == 2)
+	assert(t, counts[0].Class == jsonNotExist && counts[0].Count == 2)
+	assert(t, len(counts[0].Exemplars) == 1)
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// A Classifier returns the error class for err, or nil if it doesn't know
+// err. Classifiers are given errors that aren't *Errors, one layer at a time;
+// the errors wrapped by *os.PathError and similar wrapper types are passed to
+// them separately.
+type Classifier func(err error) *ErrorClass
+
+type registeredClassifier struct {
+	classify Classifier
+	priority int
+}
+
+// classifiers holds the registered classifiers, sorted by descending priority.
+var classifiers struct {
+	mu   sync.RWMutex
+	list []registeredClassifier
+}
+
+// RegisterClassifier adds a Classifier that GetClass and ErrorClass.Contains
+// consult before the built-in classification of standard library errors. The
+// built-in classification is only used if no registered Classifier knows any
+// layer of the error. Classifiers with a higher priority are consulted first,
+// and classifiers with the same priority are consulted in the order they were
+// registered.
+func RegisterClassifier(priority int, classifier Classifier) {
+	classifiers.mu.Lock()
+	defer classifiers.mu.Unlock()
+	old := classifiers.list
+	i := sort.Search(len(old), func(i int) bool {
+		return old[i].priority < priority
+	})
+	// the list is copied rather than modified in place so classify can use it
+	// without holding the lock.
+	list := make([]registeredClassifier, 0, len(old)+1)
+	list = append(list, old[:i]...)
+	list = append(list, registeredClassifier{
+		classify: classifier, priority: priority})
+	classifiers.list = append(list, old[i:]...)
+}
+
+// classify returns the first class a registered Classifier returns for err,
+// or nil.
+func classify(err error) *ErrorClass {
+	classifiers.mu.RLock()
+	list := classifiers.list
+	classifiers.mu.RUnlock()
+	for _, c := range list {
+		if class := c.classify(err); class != nil {
+			return class
+		}
+	}
+	return nil
+}
+
+// classifyStdlibError is the built-in classification of encoding/json,
+// strconv, net/url and crypto/x509 errors.
+func classifyStdlibError(err error) *ErrorClass {
+	switch err := err.(type) {
+	case *json.SyntaxError:
+		return JSONSyntaxError
+	case *json.UnmarshalTypeError:
+		return JSONTypeError
+	case *json.MarshalerError:
+		return JSONMarshalerError
+	case *json.InvalidUnmarshalError, *json.UnsupportedTypeError,
+		*json.UnsupportedValueError:
+		return JSONError
+	case *strconv.NumError:
+		switch err.Err {
+		case strconv.ErrSyntax:
+			return NumSyntaxError
+		case strconv.ErrRange:
+			return NumRangeError
+		}
+		return NumError
+	case *url.Error:
+		return URLError
+	case url.EscapeError, url.InvalidHostError:
+		return URLEscapeError
+	case x509.UnknownAuthorityError:
+		return UnknownAuthorityError
+	case x509.HostnameError:
+		return HostnameError
+	case x509.CertificateInvalidError:
+		return CertificateInvalidError
+	case x509.ConstraintViolationError, x509.UnhandledCriticalExtension,
+		x509.InsecureAlgorithmError, x509.SystemRootsError:
+		return X509Error
+	}
+	return nil
+}
@@ -18,7 +18,16 @@ package errors
 //   github.com/spacemonkeygo/flagfile/utils.Setup
 // but can be set independently.
 var Config = struct {
-	Stacklogsize int `default:"4096" usage:"the max stack trace byte length to log"`
+	Stacklogsize    int    `default:"4096" usage:"the max stack trace byte length to log"`
+	Legacyformat    bool   `default:"false" usage:"format errors with %v and %s as Error() does, including stacks"`
+	Stackinternsize int    `default:"4096" usage:"the max number of distinct captured stacks to share between errors"`
+	Stackdepth      int    `default:"256" usage:"the max number of frames to capture in error stacks"`
+	Stackskip       int    `default:"0" usage:"the number of innermost frames to skip when capturing error stacks"`
+	Stackinclude    string `default:"" usage:"comma-separated package prefixes; if set, only frames from these packages are printed in backtraces"`
+	Stackexclude    string `default:"" usage:"comma-separated package prefixes of frames to hide from backtraces"`
+	Printdata       bool   `default:"false" usage:"include the data of named data keys when formatting errors with Error() or %+v"`
 }{
-	Stacklogsize: 4096,
+	Stacklogsize:    4096,
+	Stackinternsize: 4096,
+	Stackdepth:      256,
 }
@@ -15,6 +15,9 @@
 package errors
 
 import (
+	"reflect"
+	"sort"
+	"sync"
 	"sync/atomic"
 )
 
@@ -30,3 +33,154 @@ type DataKey struct{ id int32 }
 
 // GenSym generates a brand new, never-before-seen DataKey
 func GenSym() DataKey { return DataKey{id: atomic.AddInt32(&lastId, 1)} }
+
+// GenSymNamed is like GenSym, but gives the DataKey a name for debugging. The
+// name is what AllData reports for the key and what Error prints when
+// Config.Printdata is set. Unlike the names given to RegisterDataKey, these
+// names don't have to be unique.
+func GenSymNamed(name string) DataKey {
+	key := GenSym()
+	dataKeys.mu.Lock()
+	defer dataKeys.mu.Unlock()
+	dataKeys.names[key] = name
+	return key
+}
+
+// Name returns the name the key was given by GenSymNamed, or else the name it
+// was registered under with RegisterDataKey, or else "".
+func (k DataKey) Name() string {
+	dataKeys.mu.RLock()
+	defer dataKeys.mu.RUnlock()
+	if name, ok := dataKeys.names[k]; ok {
+		return name
+	}
+	return dataKeys.byKey[k].name
+}
+
+// dataKeys holds the DataKeys registered with RegisterDataKey, by name and by
+// key, and the names of the DataKeys made with GenSymNamed.
+var dataKeys = struct {
+	mu     sync.RWMutex
+	byName map[string]registeredKey
+	byKey  map[DataKey]registeredKey
+	names  map[DataKey]string
+}{
+	byName: make(map[string]registeredKey),
+	byKey:  make(map[DataKey]registeredKey),
+	names:  make(map[DataKey]string),
+}
+
+type registeredKey struct {
+	key  DataKey
+	name string
+	typ  reflect.Type
+}
+
+// RegisterDataKey makes values stored with the given DataKey part of an
+// error's serialized form, such as its JSON encoding. name identifies the key
+// across processes and so must be the same everywhere, and unique; a package
+// path prefix is a good idea. Values are decoded into the type of prototype,
+// so the values stored with the key should all have that type. It panics if
+// the name or key is already registered, or if prototype is nil.
+func RegisterDataKey(name string, key DataKey, prototype interface{}) {
+	if prototype == nil {
+		panic("data key prototype must not be nil")
+	}
+	dataKeys.mu.Lock()
+	defer dataKeys.mu.Unlock()
+	if _, ex := dataKeys.byName[name]; ex {
+		panic("data key name already registered")
+	}
+	if _, ex := dataKeys.byKey[key]; ex {
+		panic("data key already registered")
+	}
+	rk := registeredKey{key: key, name: name, typ: reflect.TypeOf(prototype)}
+	dataKeys.byName[name] = rk
+	dataKeys.byKey[key] = rk
+}
+
+func lookupDataKeyName(name string) (registeredKey, bool) {
+	dataKeys.mu.RLock()
+	defer dataKeys.mu.RUnlock()
+	rk, ok := dataKeys.byName[name]
+	return rk, ok
+}
+
+func lookupDataKey(key DataKey) (registeredKey, bool) {
+	dataKeys.mu.RLock()
+	defer dataKeys.mu.RUnlock()
+	rk, ok := dataKeys.byKey[key]
+	return rk, ok
+}
+
+// DataEntry is a piece of data on an error, as returned by AllData.
+type DataEntry struct {
+	Name  string
+	Key   DataKey
+	Value interface{}
+}
+
+// AllData returns the data GetData would find on err under every named
+// DataKey, sorted by name. Data set on err itself takes precedence over its
+// class's data, and its class's data is left out if err was made with
+// DisableInheritance. Data added with WithData takes precedence over the data
+// of the error it was added to. Data stored under keys without a name isn't
+// included.
+func AllData(err error) []DataEntry {
+	data := visibleData(err)
+	if data == nil {
+		return nil
+	}
+	return dataEntries(data)
+}
+
+func (e *Error) allData() []DataEntry {
+	return dataEntries(visibleData(e))
+}
+
+// visibleData returns all of the data GetData would find on err, or nil if
+// err can't have data.
+func visibleData(err error) map[DataKey]interface{} {
+	switch cast := err.(type) {
+	case *Error:
+		data := make(map[DataKey]interface{},
+			len(cast.data)+len(cast.class.data))
+		if !boolWrapper(cast.data[disableInheritance], false) {
+			for key, value := range cast.class.data {
+				data[key] = value
+			}
+		}
+		for key, value := range cast.data {
+			data[key] = value
+		}
+		delete(data, disableInheritance)
+		delete(data, messageContext)
+		return data
+	case *annotatedError:
+		data := visibleData(cast.err)
+		if data == nil {
+			data = make(map[DataKey]interface{}, len(cast.data))
+		}
+		for key, value := range cast.data {
+			data[key] = value
+		}
+		return data
+	}
+	return nil
+}
+
+func dataEntries(data map[DataKey]interface{}) []DataEntry {
+	entries := make([]DataEntry, 0, len(data))
+	for key, value := range data {
+		if name := key.Name(); name != "" {
+			entries = append(entries, DataEntry{Name: name, Key: key, Value: value})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Key.id < entries[j].Key.id
+	})
+	return entries
+}
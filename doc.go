@@ -75,6 +75,35 @@ the os error handling routines can be mimicked as follows:
     // do stuff
   }
 
+Errors generated by this package also work with the standard library's
+errors.Is, errors.As and errors.Unwrap, which see through to the error that
+was wrapped:
+
+  err := NotExist.Wrap(io.EOF)
+  errors.Is(err, io.EOF) // true
+
+Class membership can be tested the same way through a class' Sentinel:
+
+  errors.Is(err, NotExist.Sentinel()) // true
+
+To support this, (*Error).Is now has the signature errors.Is expects,
+Is(target error) bool. Code written against the old Is(ec *ErrorClass,
+opts ...EquivalenceOption) no longer compiles and should ask the class
+instead, passing along the same options:
+
+  err.Is(NotExist, errors.IncludeWrapped)       // before
+  NotExist.Contains(err, errors.IncludeWrapped) // now
+
+To explain what was going on when an error happened, Wrapf wraps an error in
+a class with some added context, and Annotate adds context without changing
+the class:
+
+  err := Storage.Wrapf(err, "reading %s", path)
+  // err.Error() starts with "Storage: reading /some/path: ..."
+
+Causes, RootCause and Walk go through everything an error wraps, including
+the errors collected in an ErrorGroup.
+
 Stack traces
 
 It doesn't take long during Go development before you may find yourself
@@ -88,6 +117,8 @@ stack trace as part of your error. This behavior can be turned off and on for
 specific error classes and comes in two flavors. You can have the stack trace
 be appended to the error's Error() message, or you can have the stack trace
 be logged immediately, every time an error of that type is instantiated.
+Formatting an error with %v or %s prints only its message, while %+v prints
+the stack trace too, just like Error() does.
 
 Every error and error class supports hierarchical settings, in the sense that
 if a setting was not explicitly set on that error or error class, setting
@@ -95,7 +126,10 @@ resolution traverses the error class hierarchy until it finds a valid setting,
 or returns the default.
 
 See CaptureStack()/NoCaptureStack() and LogOnCreation()/NoLogOnCreation() for
-how to control this feature.
+how to control this feature. Logged errors go through a Logger, which can be
+changed for the whole package with SetLogger or for a class with LogTo().
+StackDepth(), SkipFrames(), IncludePackages() and ExcludePackages() (or the
+matching Config fields) control which frames are captured and printed.
 
 Arbitrary error values
 
@@ -151,6 +185,22 @@ be mimicked as follows:
     }
   }
 
+With Go 1.18 or newer, a Key does the type assertion for you:
+
+  var tempErrorKey = errors.NewKey[bool]()
+
+  func SetIsTemporary() errors.ErrorOption {
+    return tempErrorKey.Set(true)
+  }
+
+  func IsTemporary(err error) bool {
+    return tempErrorKey.GetOr(err, false)
+  }
+
+To add data to an error you didn't create, such as a request ID in some
+middleware, use WithData. It keeps the error's class, message and stack rather
+than wrapping it in a new class.
+
 HTTP handling
 
 Another great example of arbitrary error value functionality is the errhttp
@@ -186,17 +236,38 @@ to the error's output. As an example:
 errors.Record will help you keep track of which error handling branch your
 code took.
 
+Serialization
+
+Errors can be sent to other processes as JSON, or in a more compact binary
+form with MarshalBinary or EncodeBinary. The receiving side gets an
+error of the same class (looked up by its path, see LookupClass), so Contains
+keeps working. Data is only sent for keys registered with RegisterDataKey.
+
 ErrorGroup
 
 There's a few different types of ErrorGroup utilities in this package, but they
 all work the same way. Make sure to check out the ErrorGroup example.
 
+Group runs tasks in goroutines, optionally limiting how many run at once and
+canceling a Context when one fails, and collects their errors in an
+ErrorGroup.
+
 CatchPanic
 
 CatchPanic helps you easily manage functions that you think might panic, and
 instead return errors. CatchPanic works by taking a pointer to your named error
 return value. Check out the CatchPanic example for more.
 
+The errors CatchPanic returns have a stack that starts where the panic
+happened, keep the value the panic was called with (see GetPanicValue), and
+are classified into RuntimePanicError subclasses like NilDereferenceError or
+IndexOutOfRangeError when the panic came from the runtime.
+
+For goroutines, Go and GoContext run a function in a new goroutine and hand
+any error it returns, or a PanicError if it panics, to a handler such as an
+ErrorGroup's Add method. PanicErrors also record the stack that started the
+goroutine.
+
 Footnotes
 
 [1] This errors package started while porting a large Python codebase to Go.
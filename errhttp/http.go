@@ -32,6 +32,11 @@ var (
 	errorBody  = errors.GenSym()
 )
 
+func init() {
+	errors.RegisterDataKey("errhttp.statusCode", statusCode, 0)
+	errors.RegisterDataKey("errhttp.errorBody", errorBody, "")
+}
+
 // SetStatusCode returns an ErrorOption (for use in ErrorClass creation or
 // error instantiation) that controls the error's HTTP status code
 func SetStatusCode(code int) errors.ErrorOption {
@@ -19,9 +19,9 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/url"
 	"os"
-	"path/filepath"
-	"runtime"
+	"strconv"
 	"strings"
 )
 
@@ -29,6 +29,7 @@ var (
 	logOnCreation      = GenSym()
 	captureStack       = GenSym()
 	disableInheritance = GenSym()
+	messageContext     = GenSym()
 )
 
 // ErrorClass is the basic hierarchical error type. An ErrorClass generates
@@ -38,24 +39,27 @@ var (
 type ErrorClass struct {
 	parent *ErrorClass
 	name   string
+	path   string
 	data   map[DataKey]interface{}
 }
 
 var (
 	// HierarchicalError is the base class for all hierarchical errors generated
 	// through this class.
-	HierarchicalError = &ErrorClass{
+	HierarchicalError = registerClass(&ErrorClass{
 		parent: nil,
 		name:   "Error",
-		data:   map[DataKey]interface{}{captureStack: true}}
+		path:   "Error",
+		data:   map[DataKey]interface{}{captureStack: true}})
 
 	// SystemError is the base error class for errors not generated through this
 	// errors library. It is not expected that anyone would ever generate new
 	// errors from a SystemError type or make subclasses.
-	SystemError = &ErrorClass{
+	SystemError = registerClass(&ErrorClass{
 		parent: nil,
 		name:   "System Error",
-		data:   map[DataKey]interface{}{}}
+		path:   "System Error",
+		data:   map[DataKey]interface{}{}})
 )
 
 // An ErrorOption is something that controls behavior of specific error
@@ -126,13 +130,22 @@ func New(text string) error {
 }
 
 // NewClass creates an error class with the provided name and options. The new
-// class will descend from the receiver.
+// class will descend from the receiver, and is registered under the receiver's
+// path followed by "/" and name. See LookupClass.
 func (parent *ErrorClass) NewClass(name string,
 	options ...ErrorOption) *ErrorClass {
+	return parent.newClass(name, parent.path+"/"+name, options)
+}
+
+// newClass is NewClass with the registered path given separately, for
+// classes that share their name with another class.
+func (parent *ErrorClass) newClass(name, path string,
+	options []ErrorOption) *ErrorClass {
 
 	ec := &ErrorClass{
 		parent: parent,
 		name:   name,
+		path:   path,
 		data:   make(map[DataKey]interface{})}
 
 	for _, option := range options {
@@ -147,12 +160,11 @@ func (parent *ErrorClass) NewClass(name string,
 				ec.data[key] = val
 			}
 		}
-		return ec
 	} else {
 		delete(ec.data, disableInheritance)
 	}
 
-	return ec
+	return registerClass(ec)
 }
 
 // MustAddData allows adding data key value pairs to error classes after they
@@ -177,6 +189,12 @@ func (e *ErrorClass) Parent() *ErrorClass {
 	return e.parent
 }
 
+// Path returns this error class' fully qualified name: the names of its
+// ancestors and itself joined by "/", such as "Error/Storage/Not Exist".
+func (e *ErrorClass) Path() string {
+	return e.path
+}
+
 // String returns this error class' name
 func (e *ErrorClass) String() string {
 	if e == nil {
@@ -195,31 +213,24 @@ func (e *ErrorClass) Is(parent *ErrorClass) bool {
 	return false
 }
 
-// frame logs the pc at some point during execution.
-type frame struct {
-	pc uintptr
+// Sentinel returns an error value standing in for the receiver class, for use
+// as the target of the standard library's errors.Is. errors.Is(err,
+// class.Sentinel()) is true whenever class.Contains(err, IncludeWrapped) would
+// be, as long as the chain includes at least one error generated by this
+// package; plain system errors such as io.EOF don't know about classes.
+// Sentinel values for the same class compare equal.
+func (e *ErrorClass) Sentinel() error {
+	return classSentinel{class: e}
 }
 
-// String returns a human readable form of the frame.
-func (e frame) String() string {
-	if e.pc == 0 {
-		return "unknown.unknown:0"
-	}
-	f := runtime.FuncForPC(e.pc)
-	if f == nil {
-		return "unknown.unknown:0"
-	}
-	file, line := f.FileLine(e.pc)
-	return fmt.Sprintf("%s:%s:%d", f.Name(), filepath.Base(file), line)
+// classSentinel is the error returned by ErrorClass.Sentinel.
+type classSentinel struct {
+	class *ErrorClass
 }
 
-// callerState records the pc into an frame for two callers up.
-func callerState(depth int) frame {
-	pc, _, _, ok := runtime.Caller(depth)
-	if !ok {
-		return frame{pc: 0}
-	}
-	return frame{pc: pc}
+// Error returns the name of the class the sentinel stands in for.
+func (s classSentinel) Error() string {
+	return s.class.String()
 }
 
 // record will record the pc at the given depth into the error if it is
@@ -257,8 +268,8 @@ func RecordBefore(err error, depth int) error {
 type Error struct {
 	err    error
 	class  *ErrorClass
-	stacks [][]frame
-	exits  []frame
+	stacks []*stackTrace
+	exits  []*stackTrace
 	data   map[DataKey]interface{}
 }
 
@@ -278,22 +289,102 @@ func (e *Error) GetData(key DataKey) interface{} {
 }
 
 // GetData returns the value associated with the given DataKey on this error
-// or any of its ancestors. Please see the example for SetData
-func GetData(err error, key DataKey) interface{} {
-	cast, ok := err.(*Error)
-	if ok {
-		return cast.GetData(key)
+// or any of its ancestors. Please see the example for SetData. Data added with
+// WithData is found as well. If IncludeWrapped is given and err has no value
+// for key, the errors it wraps are searched too, outermost first.
+func GetData(err error, key DataKey, opts ...EquivalenceOption) interface{} {
+	includeWrapped := combineEquivOpts(opts)&IncludeWrapped != 0
+	for err != nil {
+		switch cast := err.(type) {
+		case *Error:
+			val := cast.GetData(key)
+			if val != nil || !includeWrapped {
+				return val
+			}
+			err = cast.err
+		case *annotatedError:
+			if val, ok := cast.data[key]; ok {
+				return val
+			}
+			err = cast.err
+		default:
+			if !includeWrapped {
+				return nil
+			}
+			if multi, ok := err.(interface{ Unwrap() []error }); ok {
+				for _, child := range multi.Unwrap() {
+					if val := GetData(child, key, opts...); val != nil {
+						return val
+					}
+				}
+				return nil
+			}
+			err = errors.Unwrap(err)
+		}
 	}
 	return nil
 }
 
+// WithData returns err with the data from the given options added, without
+// wrapping it in another error class. If err is an *Error, the result is a
+// copy of it that shares its class, stacks and exits. Otherwise, the result
+// wraps err, taking its message and class from err. WithData returns nil if
+// err is nil.
+func WithData(err error, options ...ErrorOption) error {
+	switch cast := err.(type) {
+	case nil:
+		return nil
+	case *Error:
+		rv := *cast
+		// limit the capacity so AttachStack on either error can't affect the
+		// other.
+		rv.stacks = cast.stacks[:len(cast.stacks):len(cast.stacks)]
+		rv.exits = cast.exits[:len(cast.exits):len(cast.exits)]
+		rv.data = copyData(cast.data, options)
+		return &rv
+	case *annotatedError:
+		return &annotatedError{err: cast.err, context: cast.context,
+			data: copyData(cast.data, options)}
+	}
+	return &annotatedError{err: err, data: copyData(nil, options)}
+}
+
+func copyData(data map[DataKey]interface{},
+	options []ErrorOption) map[DataKey]interface{} {
+	rv := make(map[DataKey]interface{}, len(data)+len(options))
+	for key, value := range data {
+		rv[key] = value
+	}
+	for _, option := range options {
+		option(rv)
+	}
+	return rv
+}
+
+// annotatedError holds the data and context WithData and Annotate add to an
+// error that isn't an *Error. Its class is the class of the error it wraps.
+type annotatedError struct {
+	err     error
+	context string
+	data    map[DataKey]interface{}
+}
+
+func (e *annotatedError) Error() string {
+	if e.context != "" {
+		return e.context + ": " + e.err.Error()
+	}
+	return e.err.Error()
+}
+
+func (e *annotatedError) Unwrap() error { return e.err }
+
 func (e *ErrorClass) wrap(err error, classes []*ErrorClass,
 	options []ErrorOption) error {
 	if err == nil {
 		return nil
 	}
 	if ec, ok := err.(*Error); ok {
-		if ec.Is(e) {
+		if ec.class.Is(e) {
 			if len(options) == 0 {
 				return ec
 			}
@@ -301,7 +392,7 @@ func (e *ErrorClass) wrap(err error, classes []*ErrorClass,
 			// mutate the existing error.
 		} else {
 			for _, class := range classes {
-				if ec.Is(class) {
+				if ec.class.Is(class) {
 					return err
 				}
 			}
@@ -317,22 +408,24 @@ func (e *ErrorClass) wrap(err error, classes []*ErrorClass,
 	}
 
 	if boolWrapper(rv.GetData(captureStack), false) {
-		rv.stacks = [][]frame{getStack(3)}
-	}
-	if boolWrapper(rv.GetData(logOnCreation), false) {
-		LogWithStack(rv.Error())
+		rv.stacks = []*stackTrace{rv.captureStack(3)}
 	}
+	rv.logCreation()
 	return rv
 }
 
-func getStack(depth int) (stack []frame) {
-	var pcs [256]uintptr
-	amount := runtime.Callers(depth+1, pcs[:])
-	stack = make([]frame, amount)
-	for i := 0; i < amount; i++ {
-		stack[i] = frame{pcs[i]}
+// logCreation logs the newly created receiver if it asked to be logged on
+// creation.
+func (e *Error) logCreation() {
+	if boolWrapper(e.GetData(logOnCreation), false) {
+		l := loggerFor(e)
+		msg := e.Message()
+		if _, ok := l.(logMethodLogger); ok {
+			// the default logger has always logged the backtrace and exits.
+			msg = e.Error()
+		}
+		logWithStack(l, msg, Field{ErrorField, e})
 	}
-	return stack
 }
 
 // AttachStack adds another stack to the current error's stack trace if it
@@ -349,7 +442,15 @@ func AttachStack(err error) {
 		// only record stacks if this error was supposed to
 		return
 	}
-	cast.stacks = append(cast.stacks, getStack(2))
+	cast.attachStack(cast.captureStack(2))
+}
+
+// attachStack adds stack to the receiver's stack trace if it records stacks.
+func (e *Error) attachStack(stack *stackTrace) {
+	if len(e.stacks) < 1 {
+		return
+	}
+	e.stacks = append(e.stacks, stack)
 }
 
 // WrapUnless wraps the given error in the receiver error class unless the
@@ -364,6 +465,40 @@ func (e *ErrorClass) Wrap(err error, options ...ErrorOption) error {
 	return e.wrap(err, nil, options)
 }
 
+// Wrapf wraps the given error in the receiver error class, adding the
+// formatted context to the front of its message, as in
+// "Class: context: message". Unlike Wrap, Wrapf adds a new layer even if err
+// is already in the receiver class, so the context isn't lost.
+func (e *ErrorClass) Wrapf(err error, format string,
+	args ...interface{}) error {
+	return e.wrap(err, nil, []ErrorOption{
+		SetData(messageContext, fmt.Sprintf(format, args...))})
+}
+
+// Annotate adds the formatted context to the front of err's message without
+// changing its class. An *Error keeps its stacks and exits, as with WithData,
+// and any other error is wrapped, so errors.Is and errors.As still find it.
+// Annotate returns nil if err is nil.
+func Annotate(err error, format string, args ...interface{}) error {
+	context := fmt.Sprintf(format, args...)
+	switch cast := err.(type) {
+	case nil:
+		return nil
+	case *Error:
+		if prev, ok := cast.data[messageContext].(string); ok && prev != "" {
+			context += ": " + prev
+		}
+		return WithData(cast, SetData(messageContext, context))
+	case *annotatedError:
+		if cast.context != "" {
+			context += ": " + cast.context
+		}
+		return &annotatedError{err: cast.err, data: cast.data,
+			context: context}
+	}
+	return &annotatedError{err: err, context: context}
+}
+
 // New makes a new error type. It takes a format string.
 func (e *ErrorClass) New(format string, args ...interface{}) error {
 	return e.wrap(fmt.Errorf(format, args...), nil, nil)
@@ -377,13 +512,19 @@ func (e *ErrorClass) NewWith(message string, options ...ErrorOption) error {
 // Error conforms to the error interface. Error will return the backtrace if
 // it was captured and any recorded exits.
 func (e *Error) Error() string {
-	message := strings.TrimRight(e.err.Error(), "\n ")
+	message := e.withContext(strings.TrimRight(e.err.Error(), "\n "))
 	if strings.Contains(message, "\n") {
 		message = fmt.Sprintf("%s:\n  %s", e.class.String(),
 			strings.Replace(message, "\n", "\n  ", -1))
 	} else {
 		message = fmt.Sprintf("%s: %s", e.class.String(), message)
 	}
+	if Config.Printdata {
+		if data := e.dataString(); data != "" {
+			message = fmt.Sprintf(
+				"%s\n\"%s\" data:\n%s", message, e.class, data)
+		}
+	}
 	if stack := e.Stack(); stack != "" {
 		message = fmt.Sprintf(
 			"%s\n\"%s\" backtrace:\n%s", message, e.class, stack)
@@ -395,9 +536,59 @@ func (e *Error) Error() string {
 	return message
 }
 
+// dataString returns the receiver's named data, one "name: value" per line.
+func (e *Error) dataString() string {
+	entries := e.allData()
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		lines = append(lines, fmt.Sprintf("%s: %v", entry.Name, entry.Value))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Format implements fmt.Formatter. The %v and %s verbs print the same as
+// Message, %+v prints the same as Error, and %q prints a quoted Message. If
+// Config.Legacyformat is set, %v, %s and %q use Error instead of Message.
+// Widths, precisions and flags are applied as they would be to a string.
+func (e *Error) Format(f fmt.State, verb rune) {
+	message := e.Message
+	if Config.Legacyformat || (verb == 'v' && f.Flag('+')) {
+		message = e.Error
+	}
+	switch verb {
+	case 'v', 's':
+		fmt.Fprintf(f, formatDirective(f, 's'), message())
+	case 'q':
+		fmt.Fprintf(f, formatDirective(f, 'q'), message())
+	default:
+		fmt.Fprintf(f, "%%!%c(*errors.Error=%s)", verb, e.Message())
+	}
+}
+
+// formatDirective rebuilds the directive f was created for, such as "%-10v",
+// with verb in place of the original verb.
+func formatDirective(f fmt.State, verb rune) string {
+	var b strings.Builder
+	b.WriteByte('%')
+	for _, flag := range "-+# 0" {
+		if f.Flag(int(flag)) {
+			b.WriteRune(flag)
+		}
+	}
+	if width, ok := f.Width(); ok {
+		b.WriteString(strconv.Itoa(width))
+	}
+	if precision, ok := f.Precision(); ok {
+		b.WriteByte('.')
+		b.WriteString(strconv.Itoa(precision))
+	}
+	b.WriteRune(verb)
+	return b.String()
+}
+
 // Message returns just the error message without the backtrace or exits.
 func (e *Error) Message() string {
-	message := strings.TrimRight(GetMessage(e.err), "\n ")
+	message := e.withContext(strings.TrimRight(GetMessage(e.err), "\n "))
 	if strings.Contains(message, "\n") {
 		return fmt.Sprintf("%s:\n  %s", e.class.String(),
 			strings.Replace(message, "\n", "\n  ", -1))
@@ -405,6 +596,15 @@ func (e *Error) Message() string {
 	return fmt.Sprintf("%s: %s", e.class.String(), message)
 }
 
+// withContext prepends the context added by Wrapf or Annotate, if any, to
+// message.
+func (e *Error) withContext(message string) string {
+	if context, ok := e.data[messageContext].(string); ok && context != "" {
+		return context + ": " + message
+	}
+	return message
+}
+
 // WrappedErr returns the wrapped error, if the current error is simply
 // wrapping some previously returned error or system error. You probably want
 // the package-level WrappedErr
@@ -412,6 +612,12 @@ func (e *Error) WrappedErr() error {
 	return e.err
 }
 
+// Unwrap returns the wrapped error. It exists so that the standard library's
+// errors.Is, errors.As and errors.Unwrap can walk through hierarchical errors.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
 // WrappedErr returns the wrapped error, if the current error is simply
 // wrapping some previously returned error or system error. If the error isn't
 // hierarchical it is just returned.
@@ -423,6 +629,76 @@ func WrappedErr(err error) error {
 	return cast.WrappedErr()
 }
 
+// Walk calls fn with err and then with every error err wraps, depth first,
+// until fn returns false. It follows *Error layers, errors with an
+// Unwrap() error or Unwrap() []error method, and the errors collected in a
+// finalized ErrorGroup.
+func Walk(err error, fn func(error) bool) {
+	walk(err, fn)
+}
+
+func walk(err error, fn func(error) bool) bool {
+	if err == nil {
+		return true
+	}
+	if !fn(err) {
+		return false
+	}
+	for _, child := range wrappedErrors(err) {
+		if !walk(child, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// wrappedErrors returns the errors err directly wraps.
+func wrappedErrors(err error) []error {
+	// the errors of a finalized ErrorGroup are held by an unexported type
+	// that is of no use to callers, so go straight to the errors themselves.
+	if cast, ok := err.(*Error); ok {
+		if group, ok := cast.err.(*groupErrors); ok {
+			return group.Unwrap()
+		}
+	}
+	switch cast := err.(type) {
+	case interface{ Unwrap() []error }:
+		return cast.Unwrap()
+	case interface{ Unwrap() error }:
+		if inner := cast.Unwrap(); inner != nil {
+			return []error{inner}
+		}
+	}
+	return nil
+}
+
+// Causes returns every error err wraps, not including err itself, in the
+// order Walk visits them.
+func Causes(err error) []error {
+	var causes []error
+	Walk(err, func(cause error) bool {
+		causes = append(causes, cause)
+		return true
+	})
+	if len(causes) < 2 {
+		return nil
+	}
+	return causes[1:]
+}
+
+// RootCause returns the innermost error err wraps, following the first
+// wrapped error wherever there are several. It returns err itself if err
+// doesn't wrap anything.
+func RootCause(err error) error {
+	for {
+		wrapped := wrappedErrors(err)
+		if len(wrapped) == 0 || wrapped[0] == nil {
+			return err
+		}
+		err = wrapped[0]
+	}
+}
+
 // Class will return the appropriate error class for the given error. You
 // probably want the package-level GetClass.
 func (e *Error) Class() *ErrorClass {
@@ -444,7 +720,7 @@ func GetClass(err error) *ErrorClass {
 	}
 	cast, ok := err.(*Error)
 	if !ok {
-		return findSystemErrorClass(err)
+		return systemErrorClasses(err)[0]
 	}
 	return cast.class
 }
@@ -452,21 +728,30 @@ func GetClass(err error) *ErrorClass {
 // Stack will return the stack associated with the error if one is found. You
 // probably want the package-level GetStack.
 func (e *Error) Stack() string {
-	if len(e.stacks) > 0 {
-		var frames []string
-		for _, stack := range e.stacks {
-			if frames == nil {
-				frames = make([]string, 0, len(stack))
-			} else {
-				frames = append(frames, "----- attached stack -----")
-			}
-			for _, f := range stack {
-				frames = append(frames, f.String())
+	if len(e.stacks) == 0 {
+		return ""
+	}
+	include, exclude := e.stackFilters()
+	filtered := len(include) > 0 || len(exclude) > 0
+	if len(e.stacks) == 1 && !filtered {
+		return e.stacks[0].String()
+	}
+	var lines []string
+	for i, stack := range e.stacks {
+		if i > 0 {
+			lines = append(lines, "----- attached stack -----")
+		}
+		if !filtered {
+			if text := stack.String(); text != "" {
+				lines = append(lines, text)
 			}
+			continue
+		}
+		for _, f := range filterFrames(stack.Frames(), include, exclude) {
+			lines = append(lines, f.String())
 		}
-		return strings.Join(frames, "\n")
 	}
-	return ""
+	return strings.Join(lines, "\n")
 }
 
 // GetStack will return the stack associated with the error if one is found.
@@ -487,7 +772,7 @@ func (e *Error) Exits() string {
 	if len(e.exits) > 0 {
 		exits := make([]string, len(e.exits))
 		for i, ex := range e.exits {
-			exits[i] = ex.String()
+			exits[i] = ex.exitFrame().String()
 		}
 		return strings.Join(exits, "\n")
 	}
@@ -524,7 +809,8 @@ type EquivalenceOption int
 
 const (
 	// If IncludeWrapped is used, wrapped errors are also used for determining
-	// class membership.
+	// class membership. Wrapped errors are found by following both this
+	// package's wrapping and the standard library's Unwrap method.
 	IncludeWrapped EquivalenceOption = 1
 )
 
@@ -535,10 +821,22 @@ func combineEquivOpts(opts []EquivalenceOption) (rv EquivalenceOption) {
 	return rv
 }
 
-// Is returns whether or not an error belongs to a specific class. Typically
-// you should use Contains instead.
-func (e *Error) Is(ec *ErrorClass, opts ...EquivalenceOption) bool {
-	return ec.Contains(e, opts...)
+// Is is called by the standard library's errors.Is. It reports whether target
+// is the Sentinel of a class containing the receiver (including wrapped
+// errors), or an *Error of the same class (or an ancestor class) as the
+// receiver that wraps an equivalent error. Typically you should use Contains
+// instead; it is also the replacement for the Is(ec *ErrorClass,
+// opts ...EquivalenceOption) method this package used to have, as
+// ec.Contains(err, opts...).
+func (e *Error) Is(target error) bool {
+	switch cast := target.(type) {
+	case classSentinel:
+		return cast.class.Contains(e, IncludeWrapped)
+	case *Error:
+		return e.class.Is(cast.class) && errors.Is(e.err, cast.err)
+	default:
+		return false
+	}
 }
 
 // Contains returns whether or not the receiver error class contains the given
@@ -547,14 +845,31 @@ func (e *ErrorClass) Contains(err error, opts ...EquivalenceOption) bool {
 	if err == nil {
 		return false
 	}
+	includeWrapped := combineEquivOpts(opts)&IncludeWrapped != 0
 	cast, ok := err.(*Error)
 	if !ok {
-		return findSystemErrorClass(err).Is(e)
+		for _, class := range systemErrorClasses(err) {
+			if class.Is(e) {
+				return true
+			}
+		}
+		if !includeWrapped {
+			return false
+		}
+		if multi, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, child := range multi.Unwrap() {
+				if e.Contains(child, opts...) {
+					return true
+				}
+			}
+			return false
+		}
+		return e.Contains(errors.Unwrap(err), opts...)
 	}
 	if cast.class.Is(e) {
 		return true
 	}
-	if combineEquivOpts(opts)&IncludeWrapped == 0 {
+	if !includeWrapped {
 		return false
 	}
 	return e.Contains(cast.err, opts...)
@@ -566,6 +881,16 @@ var (
 	ProgrammerError     = NewClass("Programmer Error", LogOnCreation())
 	PanicError          = NewClass("Panic Error", LogOnCreation())
 
+	// The following PanicError descendants classify panics caught by
+	// CatchPanic whose value is a runtime.Error.
+	RuntimePanicError       = PanicError.NewClass("Runtime Panic Error")
+	NilDereferenceError     = RuntimePanicError.NewClass("Nil Dereference Error")
+	IndexOutOfRangeError    = RuntimePanicError.NewClass("Index Out Of Range Error")
+	SliceBoundsError        = RuntimePanicError.NewClass("Slice Bounds Error")
+	DivideByZeroError       = RuntimePanicError.NewClass("Divide By Zero Error")
+	NilMapAssignmentError   = RuntimePanicError.NewClass("Nil Map Assignment Error")
+	TypeAssertionPanicError = RuntimePanicError.NewClass("Type Assertion Error")
+
 	// The following SystemError descendants are provided such that the GetClass
 	// method has something to return for standard library error types not
 	// defined through this class.
@@ -573,9 +898,43 @@ var (
 	// It is not expected that anyone would create instances of these classes.
 	//
 	// from os
-	SyscallError = SystemError.NewClass("Syscall Error")
+	SyscallError          = SystemError.NewClass("Syscall Error")
+	PathError             = SystemError.NewClass("Path Error")
+	LinkError             = SystemError.NewClass("Link Error")
+	NotExistError         = SystemError.NewClass("Not Exist Error")
+	ExistError            = SystemError.NewClass("Exist Error")
+	PermissionError       = SystemError.NewClass("Permission Error")
+	ClosedError           = SystemError.NewClass("Closed Error")
+	DeadlineExceededError = SystemError.NewClass("Deadline Exceeded Error")
 	// from syscall
 	ErrnoError = SystemError.NewClass("Errno Error")
+	// specific syscall.Errno values. Errno values without a class of their own
+	// are just ErrnoErrors.
+	NotPermitted       = ErrnoError.NewClass("EPERM")
+	NoEntry            = ErrnoError.NewClass("ENOENT")
+	Interrupted        = ErrnoError.NewClass("EINTR")
+	InputOutput        = ErrnoError.NewClass("EIO")
+	BadFileDescriptor  = ErrnoError.NewClass("EBADF")
+	TryAgain           = ErrnoError.NewClass("EAGAIN")
+	NoMemory           = ErrnoError.NewClass("ENOMEM")
+	AccessDenied       = ErrnoError.NewClass("EACCES")
+	FileExists         = ErrnoError.NewClass("EEXIST")
+	NotDirectory       = ErrnoError.NewClass("ENOTDIR")
+	IsDirectory        = ErrnoError.NewClass("EISDIR")
+	InvalidArgument    = ErrnoError.NewClass("EINVAL")
+	TooManyOpenFiles   = ErrnoError.NewClass("EMFILE")
+	NoSpace            = ErrnoError.NewClass("ENOSPC")
+	ReadOnlyFileSystem = ErrnoError.NewClass("EROFS")
+	BrokenPipe         = ErrnoError.NewClass("EPIPE")
+	DirectoryNotEmpty  = ErrnoError.NewClass("ENOTEMPTY")
+	AddrInUse          = ErrnoError.NewClass("EADDRINUSE")
+	AddrNotAvailable   = ErrnoError.NewClass("EADDRNOTAVAIL")
+	NetworkUnreachable = ErrnoError.NewClass("ENETUNREACH")
+	ConnAborted        = ErrnoError.NewClass("ECONNABORTED")
+	ConnReset          = ErrnoError.NewClass("ECONNRESET")
+	TimedOut           = ErrnoError.NewClass("ETIMEDOUT")
+	ConnRefused        = ErrnoError.NewClass("ECONNREFUSED")
+	HostUnreachable    = ErrnoError.NewClass("EHOSTUNREACH")
 	// from net
 	NetworkError        = SystemError.NewClass("Network Error")
 	UnknownNetworkError = NetworkError.NewClass("Unknown Network Error")
@@ -597,9 +956,106 @@ var (
 	ContextError    = SystemError.NewClass("Context Error")
 	ContextCanceled = ContextError.NewClass("Canceled")
 	ContextTimeout  = ContextError.NewClass("Timeout")
+	// from encoding/json
+	JSONError          = SystemError.NewClass("JSON Error")
+	JSONSyntaxError    = JSONError.NewClass("JSON Syntax Error")
+	JSONTypeError      = JSONError.NewClass("JSON Type Error")
+	JSONMarshalerError = JSONError.NewClass("JSON Marshaler Error")
+	// from strconv
+	NumError       = SystemError.NewClass("Num Error")
+	NumSyntaxError = NumError.NewClass("Num Syntax Error")
+	NumRangeError  = NumError.NewClass("Num Range Error")
+	// from net/url
+	URLError       = SystemError.NewClass("URL Error")
+	URLEscapeError = URLError.NewClass("URL Escape Error")
+	// from crypto/x509
+	X509Error               = SystemError.NewClass("X509 Error")
+	UnknownAuthorityError   = X509Error.NewClass("Unknown Authority Error")
+	HostnameError           = X509Error.NewClass("Hostname Error")
+	CertificateInvalidError = X509Error.NewClass("Certificate Invalid Error")
 )
 
+// systemErrorClasses returns the classes of a non-*Error error, most specific
+// first. The errors wrapped by *os.PathError, *os.LinkError, *os.SyscallError,
+// *net.OpError and *url.Error are looked through, so an *os.PathError
+// wrapping ENOENT is a NotExistError, a NoEntry and a PathError, in that order.
+// If a registered Classifier knows any of the layers, only the classes the
+// Classifiers return are used, outermost layer first.
+func systemErrorClasses(err error) []*ErrorClass {
+	layers := []error{err}
+	for {
+		inner := unwrapSystemError(layers[len(layers)-1])
+		if inner == nil {
+			break
+		}
+		layers = append(layers, inner)
+	}
+
+	var classes []*ErrorClass
+	for _, layer := range layers {
+		if class := classify(layer); class != nil {
+			classes = append(classes, class)
+		}
+	}
+	if len(classes) > 0 {
+		return classes
+	}
+
+	classes = make([]*ErrorClass, 0, len(layers)+1)
+	if class := findOSErrorClass(layers[len(layers)-1]); class != nil {
+		classes = append(classes, class)
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		class := findSystemErrorClass(layers[i])
+		if class != SystemError || (i == 0 && len(classes) == 0) {
+			classes = append(classes, class)
+		}
+	}
+	return classes
+}
+
+// unwrapSystemError returns the error wrapped by one of the os, net or
+// net/url packages' wrapper types, or nil.
+func unwrapSystemError(err error) error {
+	switch err := err.(type) {
+	case *os.PathError:
+		return err.Err
+	case *os.LinkError:
+		return err.Err
+	case *os.SyscallError:
+		return err.Err
+	case *net.OpError:
+		return err.Err
+	case *url.Error:
+		return err.Err
+	case *annotatedError:
+		return err.err
+	}
+	return nil
+}
+
+// findOSErrorClass returns the class for the portable os error conditions
+// err stands for, such as a missing file, or nil.
+func findOSErrorClass(err error) *ErrorClass {
+	switch {
+	case os.IsNotExist(err):
+		return NotExistError
+	case os.IsExist(err):
+		return ExistError
+	case os.IsPermission(err):
+		return PermissionError
+	case err == os.ErrClosed:
+		return ClosedError
+	case err == osErrDeadlineExceeded:
+		return DeadlineExceededError
+	}
+	return nil
+}
+
 func findSystemErrorClass(err error) *ErrorClass {
+	if class := classifyStdlibError(err); class != nil {
+		return class
+	}
 	switch err {
 	case io.EOF:
 		return EOF
@@ -620,12 +1076,18 @@ func findSystemErrorClass(err error) *ErrorClass {
 	default:
 		break
 	}
-	if isErrnoError(err) {
-		return ErrnoError
+	if class := findErrnoClass(err); class != nil {
+		return class
 	}
-	switch err.(type) {
+	switch err := err.(type) {
+	case *remoteError:
+		return err.class
 	case *os.SyscallError:
 		return SyscallError
+	case *os.PathError:
+		return PathError
+	case *os.LinkError:
+		return LinkError
 	case net.UnknownNetworkError:
 		return UnknownNetworkError
 	case *net.AddrError:
@@ -16,10 +16,23 @@ package errors
 
 import (
 	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 var (
@@ -57,6 +70,28 @@ func TestBacktrace(t *testing.T) {
 	<-ch
 }
 
+func TestFrames(t *testing.T) {
+	err := testRecord0()
+	stacks := GetFrames(err)
+	assert(t, len(stacks) == 1)
+	top := stacks[0][0]
+	assert(t, top.Function == "github.com/spacemonkeygo/errors.testRecord2")
+	assert(t, top.Package == "github.com/spacemonkeygo/errors")
+	assert(t, strings.HasSuffix(top.File, "errors_test.go"))
+	assert(t, top.Line > 0)
+	assert(t, stacks[0][1].Function ==
+		"github.com/spacemonkeygo/errors.testRecord1")
+
+	exits := GetExitFrames(err)
+	assert(t, len(exits) == 2)
+	assert(t, exits[0].Function == "github.com/spacemonkeygo/errors.testRecord1")
+	assert(t, exits[1].Function == "github.com/spacemonkeygo/errors.testRecord0")
+	assert(t, strings.HasPrefix(GetExits(err), exits[0].String()))
+
+	assert(t, GetFrames(io.EOF) == nil)
+	assert(t, GetExitFrames(io.EOF) == nil)
+}
+
 func TestErrorGroupReturnsNilIfNoneAdded(t *testing.T) {
 	errs := NewErrorGroup()
 	err := errs.Finalize()
@@ -106,6 +141,135 @@ func TestErrorGroupLimitExceeded(t *testing.T) {
 	}
 }
 
+func TestErrorGroupKeepsErrors(t *testing.T) {
+	NotExist := NewClass("Group Not Exist")
+	path_err := &os.PathError{Op: "open", Path: "/nope", Err: os.ErrNotExist}
+	errs := NewBoundedErrorGroup(3)
+	errs.Add(fmt.Errorf("BAD"))
+	errs.Add(NotExist.New("missing"))
+	errs.Add(path_err)
+	errs.Add(fmt.Errorf("SAD"))
+	err := errs.Finalize()
+
+	assert(t, ErrorGroupError.Contains(err))
+	assert(t, !NotExist.Contains(err))
+	assert(t, NotExist.Contains(err, IncludeWrapped))
+	assert(t, !EOF.Contains(err, IncludeWrapped))
+	assert(t, stderrors.Is(err, NotExist.Sentinel()))
+	multi, ok := WrappedErr(err).(interface{ Unwrap() []error })
+	assert(t, ok && len(multi.Unwrap()) == 3)
+
+	children := GetGroupErrors(err)
+	assert(t, len(children) == 3)
+	assert(t, children[1].(*Error).Class() == NotExist)
+	assert(t, children[2] == path_err)
+	assert(t, len(GetGroupErrors(HierarchicalError.Wrap(err))) == 3)
+	assert(t, GetGroupErrors(NotExist.New("missing")) == nil)
+	assert(t, strings.Contains(GetMessage(err), "... and 1 more."))
+}
+
+func TestSummarizingErrorGroup(t *testing.T) {
+	NotExist := NewClass("Summary Not Exist")
+	errs := NewSummarizingErrorGroup(2)
+	for i := 0; i < 5; i++ {
+		errs.Add(NotExist.New("missing %d", i))
+	}
+	errs.Add(io.EOF)
+	errs.Add(nil)
+
+	counts := errs.ClassCounts()
+	assert(t, len(counts) == 2)
+	assert(t, counts[0].Class == NotExist && counts[0].Count == 5)
+	assert(t, len(counts[0].Exemplars) == 2)
+	assert(t, counts[1].Class == EOF && counts[1].Count == 1)
+
+	err := errs.Finalize()
+	assert(t, NotExist.Contains(err, IncludeWrapped))
+	assert(t, len(GetGroupErrors(err)) == 3)
+	counts = GetClassCounts(err)
+	assert(t, len(counts) == 2)
+	assert(t, counts[0].Count == 5)
+	assert(t, GetMessage(counts[0].Exemplars[0]) ==
+		"Summary Not Exist: missing 0")
+
+	expected := `Error Group Error:
+  6 errors in 2 classes:
+  CLASS                      COUNT  SAMPLE
+  Error/Summary Not Exist    5      Summary Not Exist: missing 0
+  System Error/IO Error/EOF  1      EOF`
+	assert(t, GetMessage(err) == expected)
+
+	assert(t, len(errs.ClassCounts()) == 0)
+	assert(t, errs.Finalize() == nil)
+	assert(t, GetClassCounts(NewBoundedErrorGroup(1).Finalize()) == nil)
+}
+
+func TestErrorGroupConcurrentAdd(t *testing.T) {
+	errs := NewErrorGroup()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				errs.Add(fmt.Errorf("BAD"))
+			}
+		}()
+	}
+	wg.Wait()
+	actual := errs.Finalize().Error()
+	assert(t, strings.Count(actual, "BAD") == 1000)
+}
+
+func TestGroup(t *testing.T) {
+	var g Group
+	g.SetLimit(2)
+	var mu sync.Mutex
+	running, peak := 0, 0
+	for i := 0; i < 10; i++ {
+		i := i
+		g.Go(func() error {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			if i%5 == 0 {
+				return fmt.Errorf("BAD")
+			}
+			return nil
+		})
+	}
+	err := g.Wait()
+	assert(t, ErrorGroupError.Contains(err))
+	assert(t, strings.Count(err.Error(), "BAD") == 2)
+	assert(t, peak <= 2)
+
+	assert(t, NewGroup().Wait() == nil)
+}
+
+func TestGroupWithContext(t *testing.T) {
+	g, ctx := NewGroupWithContext(context.Background())
+	g.Go(func() error { return fmt.Errorf("BAD") })
+	g.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	err := g.Wait()
+	assert(t, strings.Contains(err.Error(), "BAD"))
+	assert(t, strings.Contains(err.Error(), "context canceled"))
+
+	g, ctx = NewGroupWithContext(context.Background())
+	g.Go(func() error { return nil })
+	assert(t, g.Wait() == nil)
+	assert(t, ctx.Err() == context.Canceled)
+}
+
 func TestLoggingErrorGroupReturnsNilIfNoneAdded(t *testing.T) {
 	logbuf.Reset()
 
@@ -145,18 +309,347 @@ func TestLoggingErrorGroupLogsAndReturnsErrIfAdded(t *testing.T) {
 	}
 }
 
+func TestFormat(t *testing.T) {
+	err := HierarchicalError.New("testing")
+	assert(t, fmt.Sprintf("%v", err) == "Error: testing")
+	assert(t, fmt.Sprintf("%s", err) == "Error: testing")
+	assert(t, fmt.Sprintf("%q", err) == `"Error: testing"`)
+	assert(t, fmt.Sprintf("%+v", err) == err.Error())
+	assert(t, strings.Contains(fmt.Sprintf("%+v", err), "backtrace"))
+	assert(t, fmt.Sprintf("%20s|", err) == "      Error: testing|")
+	assert(t, fmt.Sprintf("%-16v|", err) == "Error: testing  |")
+	assert(t, fmt.Sprintf("%.5s", err) == "Error")
+	assert(t, fmt.Sprintf("%#q", err) == "`Error: testing`")
+	assert(t, fmt.Sprintf("%+30v", err) == err.Error())
+
+	Config.Legacyformat = true
+	defer func() { Config.Legacyformat = false }()
+	assert(t, fmt.Sprintf("%v", err) == err.Error())
+	assert(t, fmt.Sprintf("%s", err) == err.Error())
+}
+
+var (
+	registryStorage  = NewClass("Registry Storage")
+	registryNotExist = registryStorage.NewClass("Not Exist")
+)
+
+func TestRegistry(t *testing.T) {
+	assert(t, registryNotExist.Path() == "Error/Registry Storage/Not Exist")
+	assert(t, LookupClass("Error/Registry Storage/Not Exist") == registryNotExist)
+	assert(t, LookupClass("Error/Registry Storage") == registryStorage)
+	assert(t, LookupClass("Error") == HierarchicalError)
+	assert(t, LookupClass("System Error/IO Error/EOF") == EOF)
+	assert(t, LookupClass("Error/Registry Storage/Missing") == nil)
+
+	duplicate := registryStorage.NewClass("Not Exist")
+	assert(t, duplicate != registryNotExist)
+	assert(t, LookupClass("Error/Registry Storage/Not Exist") == registryNotExist)
+	found := false
+	for _, path := range DuplicateClasses() {
+		found = found || path == "Error/Registry Storage/Not Exist"
+	}
+	assert(t, found)
+
+	classes := AllClasses()
+	for i := 1; i < len(classes); i++ {
+		assert(t, classes[i-1].Path() < classes[i].Path())
+	}
+}
+
+// builtinDuplicates holds the duplicate class paths once package
+// initialization is done, before any test creates its own duplicates.
+var builtinDuplicates []string
+
+func init() {
+	builtinDuplicates = DuplicateClasses()
+}
+
+func TestNoBuiltinDuplicateClasses(t *testing.T) {
+	if len(builtinDuplicates) > 0 {
+		t.Fatalf("duplicate class paths: %v", builtinDuplicates)
+	}
+	assert(t, LookupClass(ErrorGroupNoCaptureStackError.Path()) ==
+		ErrorGroupNoCaptureStackError)
+	assert(t, ErrorGroupNoCaptureStackError.String() == "Error Group Error")
+}
+
+type testLine struct {
+	level  Level
+	msg    string
+	fields []Field
+}
+
+type testLogger struct {
+	lines []testLine
+}
+
+func (l *testLogger) Log(level Level, msg string, fields ...Field) {
+	l.lines = append(l.lines, testLine{level: level, msg: msg, fields: fields})
+}
+
+func TestLogTo(t *testing.T) {
+	logbuf.Reset()
+	logger := new(testLogger)
+	class := NewClass("Logged", LogOnCreation(), LogTo(logger))
+	err := class.New("testing")
+
+	assert(t, logbuf.Len() == 0)
+	assert(t, len(logger.lines) == 1)
+	line := logger.lines[0]
+	assert(t, line.level == ErrorLevel)
+	assert(t, line.msg == "Logged: testing")
+	assert(t, line.fields[0] == Field{ErrorField, err})
+	assert(t, line.fields[1].Key == StackField)
+	assert(t, strings.Contains(line.fields[1].Value.(string), "TestLogTo"))
+
+	errs := NewLoggingErrorGroup("foo")
+	errs.Add(err)
+	assert(t, len(logger.lines) == 2)
+	assert(t, logger.lines[1].msg == "foo: "+err.Error())
+	assert(t, strings.Contains(logger.lines[1].msg, "backtrace"))
+	assert(t, logger.lines[1].fields[0] == Field{GroupField, "foo"})
+	assert(t, logbuf.Len() == 0)
+}
+
+func TestDefaultLoggerFormat(t *testing.T) {
+	logbuf.Reset()
+	LogWithStack("hello", "world")
+	assert(t, strings.HasPrefix(logbuf.String(), "hello world\n\ngoroutine "))
+
+	logbuf.Reset()
+	err := NewClass("Default Logged", LogOnCreation()).New("testing")
+	assert(t, strings.HasPrefix(logbuf.String(), err.Error()+"\n\ngoroutine "))
+}
+
+func TestSetLogger(t *testing.T) {
+	logbuf.Reset()
+	logger := new(testLogger)
+	SetLogger(logger)
+	defer SetLogger(nil)
+
+	LogWithStack("hello", "world")
+	assert(t, len(logger.lines) == 1)
+	assert(t, logger.lines[0].msg == "hello world")
+	assert(t, logbuf.Len() == 0)
+
+	SetLogger(nil)
+	LogWithStack("hello")
+	assert(t, strings.HasPrefix(logbuf.String(), "hello\n\ngoroutine "))
+}
+
+func TestLoggingErrorGroupClassCounts(t *testing.T) {
+	logbuf.Reset()
+
+	errs := NewLoggingErrorGroup("foo")
+	errs.Add(io.EOF)
+	errs.Add(HierarchicalError.New("BAD"))
+	errs.Add(io.EOF)
+	errs.Add(nil)
+	counts := errs.ClassCounts()
+	assert(t, len(counts) == 2)
+	assert(t, counts[0].Class == EOF && counts[0].Count == 2)
+
+	err := errs.Finalize()
+	expected := "Error Group Error: foo: 3 of 4 failed. By class: " +
+		"System Error/IO Error/EOF (2), Error (1)."
+	assert(t, GetMessage(err) == expected)
+	counts = GetClassCounts(err)
+	assert(t, len(counts) == 2)
+	assert(t, counts[1].Class == HierarchicalError && counts[1].Count == 1)
+	assert(t, len(errs.ClassCounts()) == 0)
+}
+
+func TestRateLimitedLoggingErrorGroup(t *testing.T) {
+	logbuf.Reset()
+
+	errs := NewRateLimitedLoggingErrorGroup("foo", 2, time.Hour)
+	for i := 0; i < 5; i++ {
+		errs.Add(io.EOF)
+	}
+	errs.Add(io.ErrUnexpectedEOF)
+	assert(t, strings.Count(logbuf.String(), "foo: EOF\n") == 2)
+	assert(t, strings.Count(logbuf.String(), "foo: unexpected EOF\n") == 1)
+
+	err := errs.Finalize()
+	assert(t, strings.HasPrefix(GetMessage(err),
+		"Error Group Error: foo: 6 of 6 failed."))
+	assert(t, strings.HasSuffix(logbuf.String(),
+		"foo: 3 more System Error/IO Error/EOF errors were not logged\n"))
+
+	logbuf.Reset()
+	errs = NewRateLimitedLoggingErrorGroup("foo", 1, 0)
+	errs.Add(io.EOF)
+	errs.Add(io.EOF)
+	assert(t, strings.Count(logbuf.String(), "foo: EOF\n") == 2)
+	assert(t, !strings.Contains(logbuf.String(), "not logged"))
+
+	// suppressed counts are logged in class path order
+	errs = NewRateLimitedLoggingErrorGroup("foo", 1, time.Hour)
+	for _, err := range []error{io.ErrUnexpectedEOF, io.ErrShortWrite, io.EOF} {
+		errs.Add(err)
+		errs.Add(err)
+	}
+	logbuf.Reset()
+	errs.Finalize()
+	eof := strings.Index(logbuf.String(), "IO Error/EOF errors")
+	short := strings.Index(logbuf.String(), "IO Error/Short Write Error errors")
+	unexpected := strings.Index(logbuf.String(),
+		"IO Error/Unexpected EOF Error errors")
+	assert(t, eof >= 0 && eof < short && short < unexpected)
+}
+
 func TestErrorName(t *testing.T) {
 	name, ok := HierarchicalError.New("test").(*Error).Name()
 	assert(t, ok)
 	assert(t, name == "Error")
 }
 
+func TestStdlibUnwrap(t *testing.T) {
+	err := HierarchicalError.Wrap(io.EOF)
+	assert(t, stderrors.Unwrap(err) == io.EOF)
+	assert(t, stderrors.Is(err, io.EOF))
+
+	path_err := &os.PathError{Op: "open", Path: "/nope", Err: os.ErrNotExist}
+	err = HierarchicalError.Wrap(path_err)
+	var target *os.PathError
+	assert(t, stderrors.As(err, &target))
+	assert(t, target == path_err)
+	assert(t, stderrors.Is(err, os.ErrNotExist))
+}
+
+func TestStdlibIs(t *testing.T) {
+	Storage := NewClass("Storage")
+	NotExist := Storage.NewClass("Not Exist")
+
+	err := NotExist.Wrap(io.EOF)
+	assert(t, stderrors.Is(err, NotExist.Wrap(io.EOF)))
+	assert(t, stderrors.Is(err, Storage.Wrap(io.EOF)))
+	assert(t, !stderrors.Is(Storage.Wrap(io.EOF), err))
+	assert(t, !stderrors.Is(err, NotExist.Wrap(io.ErrUnexpectedEOF)))
+
+	var cast *Error
+	assert(t, stderrors.As(fmt.Errorf("context: %w", err), &cast))
+	assert(t, cast.Class() == NotExist)
+}
+
+func TestSentinel(t *testing.T) {
+	Storage := NewClass("Storage")
+	NotExist := Storage.NewClass("Not Exist")
+
+	err := NotExist.New("missing")
+	assert(t, stderrors.Is(err, NotExist.Sentinel()))
+	assert(t, stderrors.Is(err, Storage.Sentinel()))
+	assert(t, stderrors.Is(err, HierarchicalError.Sentinel()))
+	assert(t, !stderrors.Is(err, IOError.Sentinel()))
+	assert(t, !stderrors.Is(Storage.New("broken"), NotExist.Sentinel()))
+	assert(t, NotExist.Sentinel() == NotExist.Sentinel())
+	assert(t, NotExist.Sentinel() != Storage.Sentinel())
+
+	wrapped := fmt.Errorf("context: %w", HierarchicalError.Wrap(err))
+	assert(t, stderrors.Is(wrapped, NotExist.Sentinel()))
+	assert(t, stderrors.Is(HierarchicalError.Wrap(io.EOF), EOF.Sentinel()))
+	assert(t, NotExist.Sentinel().Error() == "Not Exist")
+}
+
+func TestContainsFollowsStdlibWrapping(t *testing.T) {
+	NotExist := NewClass("Not Exist")
+	err := HierarchicalError.Wrap(
+		fmt.Errorf("context: %w", NotExist.New("missing")))
+	assert(t, !NotExist.Contains(err))
+	assert(t, NotExist.Contains(err, IncludeWrapped))
+	assert(t, EOF.Contains(fmt.Errorf("context: %w", io.EOF), IncludeWrapped))
+	assert(t, !EOF.Contains(fmt.Errorf("context: %w", io.EOF)))
+}
+
 func assert(t *testing.T, val bool) {
+	t.Helper()
 	if !val {
 		t.Fatal("assertion failed")
 	}
 }
 
+func TestStackOptions(t *testing.T) {
+	frames := GetFrames(NewClass("Shallow", StackDepth(2)).New("testing"))
+	assert(t, len(frames[0]) == 2)
+
+	skipped := NewClass("Skipped", SkipFrames(1))
+	helper := func() error { return skipped.New("testing") }
+	frames = GetFrames(helper())
+	assert(t, frames[0][0].Function ==
+		"github.com/spacemonkeygo/errors.TestStackOptions")
+
+	err := NewClass("Filtered", ExcludePackages("runtime", "testing")).
+		New("testing")
+	assert(t, !strings.Contains(GetStack(err), "runtime."))
+	assert(t, !strings.Contains(GetStack(err), "testing.tRunner"))
+	assert(t, strings.Contains(err.Error(), "TestStackOptions"))
+	assert(t, len(GetFrames(err)[0]) > strings.Count(GetStack(err), "\n")+1)
+
+	err = NewClass("Included", IncludePackages("testing")).New("testing")
+	assert(t, strings.HasPrefix(GetStack(err), "testing.tRunner"))
+
+	Config.Stackexclude = "runtime"
+	defer func() { Config.Stackexclude = "" }()
+	assert(t, !strings.Contains(GetStack(HierarchicalError.New("testing")),
+		"runtime.goexit"))
+
+	Config.Stackexclude = "runtime, testing,"
+	stack := GetStack(HierarchicalError.New("testing"))
+	assert(t, !strings.Contains(stack, "runtime.goexit"))
+	assert(t, !strings.Contains(stack, "testing.tRunner"))
+	assert(t, strings.Contains(stack, "TestStackOptions"))
+}
+
+func TestStackInterning(t *testing.T) {
+	var errs []error
+	for i := 0; i < 2; i++ {
+		errs = append(errs, HierarchicalError.New("testing"))
+	}
+	assert(t, errs[0].(*Error).stacks[0] == errs[1].(*Error).stacks[0])
+	assert(t, errs[0].(*Error).stacks[0] != testRecord2().(*Error).stacks[0])
+	assert(t, GetStack(errs[0]) == GetStack(errs[1]))
+
+	// modifying the frames of one error must not affect the other
+	stack := GetStack(errs[1])
+	GetFrames(errs[0])[0][0].Function = "CORRUPTED"
+	assert(t, GetFrames(errs[1])[0][0].Function != "CORRUPTED")
+	assert(t, GetStack(errs[1]) == stack)
+
+	// neither the stack nor the package prefixes are formatted again
+	allocs := testing.AllocsPerRun(10, func() { _ = GetStack(errs[0]) })
+	assert(t, allocs == 0)
+}
+
+func BenchmarkNew(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = HierarchicalError.New("testing")
+	}
+}
+
+func BenchmarkNewNoCaptureStack(b *testing.B) {
+	class := NewClass("No Capture Stack", NoCaptureStack())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = class.New("testing")
+	}
+}
+
+func BenchmarkWrap(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = HierarchicalError.Wrap(io.EOF)
+	}
+}
+
+func BenchmarkError(b *testing.B) {
+	err := HierarchicalError.New("testing")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = err.Error()
+	}
+}
+
 func ExampleSetData(t *testing.T) {
 	// Create our own DataKeys
 	UserMessageKey := GenSym()
@@ -218,3 +711,395 @@ func ExampleErrorGroup(t *testing.T) {
 		handle_err(err)
 	}
 }
+
+func panicNilMap() {
+	var m map[string]int
+	m["a"] = 1
+}
+
+func TestCatchPanicStack(t *testing.T) {
+	catch := func(fn func()) (err error) {
+		defer CatchPanic(&err)
+		fn()
+		return nil
+	}
+
+	err := catch(panicNilMap)
+	assert(t, NilMapAssignmentError.Contains(err))
+	assert(t, RuntimePanicError.Contains(err))
+	assert(t, PanicError.Contains(err))
+	_, ok := GetPanicValue(err).(runtime.Error)
+	assert(t, ok)
+	frames := GetFrames(err)
+	assert(t, len(frames) == 1 && len(frames[0]) > 0)
+	assert(t, strings.HasSuffix(frames[0][0].Function, ".panicNilMap"))
+
+	err = catch(func() { panic(42) })
+	assert(t, PanicError.Contains(err))
+	assert(t, !RuntimePanicError.Contains(err))
+	assert(t, GetPanicValue(err) == 42)
+	assert(t, strings.Contains(GetFrames(err)[0][0].Function, "TestCatchPanicStack"))
+
+	var p *struct{ x int }
+	err = catch(func() { p.x++ })
+	assert(t, NilDereferenceError.Contains(err))
+	assert(t, strings.Contains(GetFrames(err)[0][0].Function, "TestCatchPanicStack"))
+
+	var s []int
+	i := 3
+	assert(t, IndexOutOfRangeError.Contains(catch(func() { s[i]++ })))
+	assert(t, SliceBoundsError.Contains(catch(func() { s = s[:i] })))
+	zero := 0
+	assert(t, DivideByZeroError.Contains(catch(func() { i /= zero })))
+	var v interface{} = "string"
+	assert(t, TypeAssertionPanicError.Contains(catch(func() { _ = v.(int) })))
+	assert(t, GetPanicValue(New("not a panic")) == nil)
+
+	perr := PanicError.New("already a panic error")
+	err = catch(func() { panic(perr) })
+	assert(t, GetPanicValue(err) == perr)
+	assert(t, GetMessage(err) == GetMessage(perr))
+	assert(t, GetPanicValue(perr) == nil)
+}
+
+func TestGo(t *testing.T) {
+	errs := make(chan error, 1)
+	handler := func(err error) { errs <- err }
+
+	Go(func() error { return nil }, handler)
+	Go(func() error { return IOError.New("failed") }, handler)
+	err := <-errs
+	assert(t, IOError.Contains(err))
+
+	Go(func() error { panic("oh hai") }, handler)
+	err = <-errs
+	assert(t, PanicError.Contains(err))
+	assert(t, GetPanicValue(err) == "oh hai")
+	frames := GetFrames(err)
+	assert(t, len(frames) == 2)
+	assert(t, strings.Contains(frames[0][0].Function, "TestGo"))
+	assert(t, strings.HasSuffix(frames[1][0].Function, ".TestGo"))
+
+	var group ErrorGroup
+	done := make(chan struct{})
+	Go(func() error { panicNilMap(); return nil }, func(err error) {
+		group.Add(err)
+		close(done)
+	})
+	<-done
+	assert(t, NilMapAssignmentError.Contains(group.Finalize()))
+
+	type ctxKey struct{}
+	ctx, cancel := context.WithCancel(
+		context.WithValue(context.Background(), ctxKey{}, "value"))
+	GoContext(ctx, func(ctx context.Context) error {
+		panic(ctx.Value(ctxKey{}))
+	}, handler)
+	assert(t, GetPanicValue(<-errs) == "value")
+	cancel()
+	called := false
+	GoContext(ctx, func(context.Context) error {
+		called = true
+		return nil
+	}, handler)
+	assert(t, ContextCanceled.Contains(<-errs))
+	assert(t, !called)
+}
+
+func TestOSErrorClasses(t *testing.T) {
+	_, err := os.Open("/does/not/exist")
+	assert(t, GetClass(err) == NotExistError)
+	assert(t, NotExistError.Contains(err))
+	assert(t, PathError.Contains(err))
+	assert(t, ErrnoError.Contains(err))
+	assert(t, SystemError.Contains(err))
+	assert(t, !PermissionError.Contains(err))
+
+	err = &os.LinkError{Op: "link", Old: "a", New: "b", Err: os.ErrExist}
+	assert(t, GetClass(err) == ExistError)
+	assert(t, LinkError.Contains(err))
+
+	err = &os.PathError{Op: "open", Path: "a", Err: os.ErrPermission}
+	assert(t, GetClass(err) == PermissionError)
+	assert(t, PathError.Contains(err))
+
+	assert(t, GetClass(os.ErrNotExist) == NotExistError)
+	assert(t, GetClass(os.ErrClosed) == ClosedError)
+	assert(t, GetClass(&os.PathError{Err: stderrors.New("other")}) == PathError)
+	assert(t, GetClass(os.NewSyscallError("read", io.EOF)) == EOF)
+	assert(t, SyscallError.Contains(os.NewSyscallError("read", io.EOF)))
+
+	if osErrDeadlineExceeded != nil {
+		err = &os.PathError{Op: "read", Path: "a", Err: osErrDeadlineExceeded}
+		assert(t, GetClass(err) == DeadlineExceededError)
+	}
+
+	wrapped := fmt.Errorf("context: %w", os.ErrNotExist)
+	assert(t, !NotExistError.Contains(wrapped))
+	assert(t, NotExistError.Contains(wrapped, IncludeWrapped))
+}
+
+func TestErrnoClasses(t *testing.T) {
+	err := error(syscall.ECONNREFUSED)
+	assert(t, GetClass(err) == ConnRefused)
+	assert(t, ConnRefused.Contains(err))
+	assert(t, ErrnoError.Contains(err))
+	assert(t, ConnRefused.String() == "ECONNREFUSED")
+
+	err = &net.OpError{Op: "dial", Net: "tcp",
+		Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	assert(t, GetClass(err) == ConnRefused)
+	assert(t, NetOpError.Contains(err))
+	assert(t, SyscallError.Contains(err))
+	assert(t, !ConnReset.Contains(err))
+
+	err = &os.PathError{Op: "write", Path: "a", Err: syscall.ENOSPC}
+	assert(t, GetClass(err) == NoSpace)
+	assert(t, PathError.Contains(err))
+
+	_, err = os.Open("/does/not/exist")
+	assert(t, NoEntry.Contains(err))
+	assert(t, NotExistError.Contains(err))
+
+	assert(t, GetClass(syscall.Errno(0xfff)) == ErrnoError)
+}
+
+type classifierTestError struct{ temporary bool }
+
+func (e classifierTestError) Error() string { return "classifier test" }
+
+var (
+	classifierTemporary = SystemError.NewClass("Classifier Temporary")
+	classifierOther     = SystemError.NewClass("Classifier Other")
+	classifierOnce      sync.Once
+)
+
+func TestClassifiers(t *testing.T) {
+	classifierOnce.Do(func() {
+		RegisterClassifier(1, func(err error) *ErrorClass {
+			if _, ok := err.(classifierTestError); ok {
+				return classifierOther
+			}
+			return nil
+		})
+		RegisterClassifier(2, func(err error) *ErrorClass {
+			if cast, ok := err.(classifierTestError); ok && cast.temporary {
+				return classifierTemporary
+			}
+			return nil
+		})
+		RegisterClassifier(100, func(err error) *ErrorClass {
+			switch err := err.(type) {
+			case *os.PathError:
+				if err.Path == "classifier" {
+					return classifierOther
+				}
+			case *url.Error:
+				if err.URL == "classifier" {
+					return classifierTemporary
+				}
+			}
+			return nil
+		})
+	})
+	// registered classifiers win over the built-in classes, outermost first
+	err := error(&os.PathError{Path: "classifier", Err: syscall.ENOENT})
+	assert(t, GetClass(err) == classifierOther)
+	assert(t, !NotExistError.Contains(err))
+	err = &url.Error{URL: "classifier", Err: &os.PathError{Path: "classifier",
+		Err: syscall.ECONNREFUSED}}
+	assert(t, GetClass(err) == classifierTemporary)
+	assert(t, classifierOther.Contains(err))
+	assert(t, GetClass(&os.PathError{Path: "other", Err: syscall.ENOENT}) ==
+		NotExistError)
+
+	assert(t, GetClass(classifierTestError{temporary: true}) ==
+		classifierTemporary)
+	assert(t, GetClass(classifierTestError{}) == classifierOther)
+	assert(t, classifierOther.Contains(
+		&os.PathError{Err: classifierTestError{}}))
+
+	_, err = strconv.Atoi("nope")
+	assert(t, GetClass(err) == NumSyntaxError)
+	_, err = strconv.ParseInt("99999999999999999999", 10, 64)
+	assert(t, NumRangeError.Contains(err))
+	assert(t, NumError.Contains(err))
+
+	err = json.Unmarshal([]byte("{"), new(interface{}))
+	assert(t, GetClass(err) == JSONSyntaxError)
+	err = json.Unmarshal([]byte(`"string"`), new(int))
+	assert(t, GetClass(err) == JSONTypeError)
+
+	_, err = url.Parse("%zz")
+	assert(t, URLError.Contains(err))
+	assert(t, URLEscapeError.Contains(err))
+
+	err = &url.Error{Op: "Get", URL: "http://localhost",
+		Err: &net.OpError{Op: "dial",
+			Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}
+	assert(t, GetClass(err) == ConnRefused)
+	assert(t, URLError.Contains(err))
+
+	assert(t, GetClass(x509.UnknownAuthorityError{}) == UnknownAuthorityError)
+	assert(t, X509Error.Contains(x509.HostnameError{Host: "example.com"}))
+}
+
+var (
+	namedRetryKey = GenSymNamed("retries")
+	namedOwnerKey = GenSymNamed("owner")
+	namedClass    = NewClass("Named Class",
+		SetData(namedRetryKey, 3), SetData(namedOwnerKey, "storage"))
+)
+
+func TestAllData(t *testing.T) {
+	assert(t, namedRetryKey.Name() == "retries")
+	assert(t, GenSym().Name() == "")
+	assert(t, jsonCodeKey.Name() == "errors_test.code")
+
+	err := namedClass.NewWith("failed", SetData(namedRetryKey, 5),
+		SetData(GenSym(), "hidden"))
+	data := AllData(err)
+	assert(t, len(data) == 2)
+	assert(t, data[0].Name == "owner" && data[0].Value == "storage")
+	assert(t, data[1].Name == "retries" && data[1].Value == 5)
+	assert(t, data[1].Key == namedRetryKey)
+
+	err = namedClass.NewWith("failed", DisableInheritance(),
+		SetData(namedRetryKey, 5))
+	data = AllData(err)
+	assert(t, len(data) == 1 && data[0].Name == "retries")
+	assert(t, AllData(io.EOF) == nil)
+
+	assert(t, !strings.Contains(err.Error(), "retries"))
+	Config.Printdata = true
+	defer func() { Config.Printdata = false }()
+	assert(t, strings.Contains(err.Error(), "\"Named Class\" data:\nretries: 5"))
+	assert(t, strings.Contains(fmt.Sprintf("%+v", err), "retries: 5"))
+	assert(t, !strings.Contains(fmt.Sprintf("%v", err), "retries: 5"))
+}
+
+func TestWithData(t *testing.T) {
+	requestKey := GenSymNamed("request")
+	tenantKey := GenSymNamed("tenant")
+
+	orig := namedClass.New("failed")
+	err := WithData(orig, SetData(requestKey, "req-1"))
+	assert(t, GetData(err, requestKey) == "req-1")
+	assert(t, GetData(orig, requestKey) == nil)
+	assert(t, GetData(err, namedOwnerKey) == "storage")
+	assert(t, GetClass(err) == namedClass)
+	assert(t, err.Error() == orig.Error())
+	assert(t, GetStack(err) == GetStack(orig))
+
+	AttachStack(err)
+	assert(t, len(GetFrames(err)) == 2)
+	assert(t, len(GetFrames(orig)) == 1)
+
+	plain := WithData(io.EOF, SetData(requestKey, "req-2"))
+	plain = WithData(plain, SetData(tenantKey, "acme"))
+	assert(t, plain.Error() == "EOF")
+	assert(t, GetClass(plain) == EOF)
+	assert(t, EOF.Contains(plain))
+	assert(t, stderrors.Is(plain, io.EOF))
+	assert(t, GetData(plain, requestKey) == "req-2")
+	assert(t, GetData(plain, tenantKey) == "acme")
+	data := AllData(plain)
+	assert(t, len(data) == 2 && data[0].Name == "request")
+
+	wrapped := IOError.Wrap(plain)
+	assert(t, GetData(wrapped, tenantKey) == nil)
+	assert(t, GetData(wrapped, tenantKey, IncludeWrapped) == "acme")
+	wrapped = fmt.Errorf("context: %w", err)
+	assert(t, GetData(wrapped, requestKey) == nil)
+	assert(t, GetData(wrapped, requestKey, IncludeWrapped) == "req-1")
+
+	assert(t, WithData(nil, SetData(requestKey, "req-3")) == nil)
+}
+
+var (
+	wrapfStorage  = NewClass("Wrapf Storage")
+	wrapfNotExist = wrapfStorage.NewClass("Wrapf Not Exist")
+)
+
+func TestWrapf(t *testing.T) {
+	cause := wrapfNotExist.New("no such object")
+	err := wrapfStorage.Wrapf(cause, "reading %s", "config")
+	assert(t, GetClass(err) == wrapfStorage)
+	assert(t, GetMessage(err) ==
+		"Wrapf Storage: reading config: Wrapf Not Exist: no such object")
+	assert(t, strings.Contains(err.Error(),
+		"reading config: Wrapf Not Exist: no such object"))
+	assert(t, WrappedErr(err) == cause)
+	assert(t, !wrapfNotExist.Contains(err))
+	assert(t, wrapfNotExist.Contains(err, IncludeWrapped))
+	assert(t, stderrors.Is(err, wrapfNotExist.Sentinel()))
+	assert(t, wrapfStorage.WrapUnless(err, wrapfStorage) == err)
+	assert(t, wrapfStorage.Wrapf(nil, "reading") == nil)
+
+	// unlike Wrap, Wrapf keeps the context when err is already in the class
+	again := wrapfNotExist.Wrapf(cause, "retrying")
+	assert(t, again != cause)
+	assert(t, GetMessage(again) ==
+		"Wrapf Not Exist: retrying: Wrapf Not Exist: no such object")
+
+	annotated := Annotate(cause, "loading %d", 3)
+	assert(t, GetClass(annotated) == wrapfNotExist)
+	assert(t, GetMessage(annotated) ==
+		"Wrapf Not Exist: loading 3: no such object")
+	assert(t, GetStack(annotated) == GetStack(cause))
+	annotated = Annotate(annotated, "startup")
+	assert(t, GetMessage(annotated) ==
+		"Wrapf Not Exist: startup: loading 3: no such object")
+	assert(t, GetMessage(cause) == "Wrapf Not Exist: no such object")
+
+	plain := Annotate(io.EOF, "reading header")
+	assert(t, plain.Error() == "reading header: EOF")
+	assert(t, GetClass(plain) == EOF)
+	assert(t, stderrors.Is(plain, io.EOF))
+	plain = Annotate(WithData(plain, SetData(namedRetryKey, 1)), "open")
+	assert(t, plain.Error() == "open: reading header: EOF")
+	assert(t, GetData(plain, namedRetryKey) == 1)
+	assert(t, Annotate(nil, "nothing") == nil)
+	assert(t, len(AllData(err)) == 0)
+}
+
+func TestWalk(t *testing.T) {
+	root := io.EOF
+	inner := wrapfNotExist.Wrap(root)
+	stdlib := fmt.Errorf("loading: %w", inner)
+	err := wrapfStorage.Wrap(stdlib)
+
+	causes := Causes(err)
+	assert(t, len(causes) == 3)
+	assert(t, causes[0] == stdlib && causes[1] == inner && causes[2] == root)
+	assert(t, RootCause(err) == root)
+	assert(t, RootCause(root) == root)
+	assert(t, RootCause(nil) == nil)
+	assert(t, Causes(root) == nil)
+	assert(t, Causes(nil) == nil)
+
+	var errs ErrorGroup
+	errs.Add(err)
+	errs.Add(ProgrammerError.Wrap(io.ErrUnexpectedEOF))
+	group := errs.Finalize()
+	var classes []*ErrorClass
+	Walk(group, func(err error) bool {
+		classes = append(classes, GetClass(err))
+		return true
+	})
+	assert(t, len(classes) == 7)
+	assert(t, classes[0] == ErrorGroupError)
+	assert(t, classes[1] == wrapfStorage)
+	assert(t, classes[4] == EOF)
+	assert(t, classes[5] == ProgrammerError)
+	assert(t, classes[6] == UnexpectedEOFError)
+	assert(t, Causes(group)[0] == err)
+	assert(t, RootCause(group) == root)
+
+	var visited int
+	Walk(group, func(err error) bool {
+		visited++
+		return !wrapfNotExist.Contains(err)
+	})
+	assert(t, visited == 4)
+}
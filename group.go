@@ -0,0 +1,102 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.7 && !appengine
+// +build go1.7,!appengine
+
+package errors
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs tasks in their own goroutines and collects the errors they
+// return in an ErrorGroup. The zero value is ready to use, runs any number of
+// tasks at once, and doesn't cancel anything. A Group must not be copied after
+// first use.
+type Group struct {
+	errs   ErrorGroup
+	wg     sync.WaitGroup
+	sem    chan struct{}
+	cancel func()
+	once   sync.Once
+}
+
+// NewGroup makes a new Group.
+func NewGroup() *Group { return &Group{} }
+
+// NewGroupWithContext makes a new Group and a Context derived from ctx. The
+// Context is canceled the first time a task returns an error, or when Wait
+// returns, whichever happens first.
+func NewGroupWithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// SetLimit limits the number of tasks running at once to n. Once the limit is
+// reached, Go blocks until a task finishes. A limit of zero or less removes
+// the limit. SetLimit must not be called while tasks are running.
+func (g *Group) SetLimit(n int) {
+	if n <= 0 {
+		g.sem = nil
+		return
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// Go runs fn in a new goroutine and adds the error it returns to the group.
+func (g *Group) Go(fn func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			defer func() { <-g.sem }()
+		}
+		if err := fn(); err != nil {
+			g.errs.Add(err)
+			g.doCancel()
+		}
+	}()
+}
+
+// Wait waits for all of the tasks started with Go to finish and returns the
+// result of finalizing their errors, like ErrorGroup.Finalize.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.doCancel()
+	return g.errs.Finalize()
+}
+
+func (g *Group) doCancel() {
+	if g.cancel != nil {
+		g.once.Do(g.cancel)
+	}
+}
+
+// GoContext is like Go, but fn is called with ctx. If ctx is already done when
+// the new goroutine starts, fn isn't called and handler gets ctx's error.
+func GoContext(ctx context.Context, fn func(context.Context) error,
+	handler func(error)) {
+	task := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(ctx)
+	}
+	go runTask(task, handler, getStack(2, Config.Stackdepth))
+}
@@ -0,0 +1,251 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+func init() {
+	RegisterDataKey("errors.disableInheritance", disableInheritance, false)
+	RegisterDataKey("errors.context", messageContext, "")
+}
+
+// JSONDecodeError is the class of errors returned when decoding JSON encoded
+// errors that are malformed.
+var JSONDecodeError = NewClass("JSON Decode Error", NoCaptureStack())
+
+// remoteError stands in for an error that was not generated through this
+// package after it has been decoded, possibly in another process. It keeps the
+// class the original error was determined to be in.
+type remoteError struct {
+	class   *ErrorClass
+	message string
+	err     error
+}
+
+// Error returns the original error's message.
+func (e *remoteError) Error() string { return e.message }
+
+// Unwrap returns the decoded error the original error wrapped, if any.
+func (e *remoteError) Unwrap() error { return e.err }
+
+// resolveClass returns the class registered under path. If there is none, the
+// closest registered ancestor path is used, and HierarchicalError if there is
+// no such ancestor either.
+func resolveClass(path string) *ErrorClass {
+	for {
+		if ec := LookupClass(path); ec != nil {
+			return ec
+		}
+		slash := strings.LastIndex(path, "/")
+		if slash < 0 {
+			return HierarchicalError
+		}
+		path = path[:slash]
+	}
+}
+
+// jsonError is the JSON form of an error. *Errors always have a cause, the
+// errors of a finalized ErrorGroup have errors, and other errors have a
+// message and maybe a cause.
+type jsonError struct {
+	Class   string                     `json:"class"`
+	Message string                     `json:"message,omitempty"`
+	Cause   *jsonError                 `json:"cause,omitempty"`
+	Errors  []*jsonError               `json:"errors,omitempty"`
+	Excess  int                        `json:"excess,omitempty"`
+	Counts  []jsonClassCount           `json:"counts,omitempty"`
+	Data    map[string]json.RawMessage `json:"data,omitempty"`
+	Stacks  [][]Frame                  `json:"stacks,omitempty"`
+	Exits   []Frame                    `json:"exits,omitempty"`
+}
+
+// jsonClassCount is the JSON form of a ClassCount. The exemplars are found
+// among the errors of the group.
+type jsonClassCount struct {
+	Class string `json:"class"`
+	Count int    `json:"count"`
+}
+
+func newJSONError(err error) (*jsonError, error) {
+	if err == nil {
+		return nil, nil
+	}
+	if group, ok := err.(*groupErrors); ok {
+		rv := &jsonError{Class: SystemError.path, Excess: group.excess}
+		for _, child := range group.errs {
+			je, jerr := newJSONError(child)
+			if jerr != nil {
+				return nil, jerr
+			}
+			rv.Errors = append(rv.Errors, je)
+		}
+		for _, count := range group.counts {
+			rv.Counts = append(rv.Counts, jsonClassCount{
+				Class: count.Class.path,
+				Count: count.Count})
+		}
+		return rv, nil
+	}
+	cast, ok := err.(*Error)
+	if !ok {
+		cause, jerr := newJSONError(errors.Unwrap(err))
+		if jerr != nil {
+			return nil, jerr
+		}
+		return &jsonError{
+			Class:   GetClass(err).path,
+			Message: err.Error(),
+			Cause:   cause}, nil
+	}
+
+	cause, jerr := newJSONError(cast.err)
+	if jerr != nil {
+		return nil, jerr
+	}
+	rv := &jsonError{
+		Class:  cast.class.path,
+		Cause:  cause,
+		Stacks: cast.Frames(),
+		Exits:  cast.ExitFrames()}
+	for key, val := range cast.data {
+		rk, ok := lookupDataKey(key)
+		if !ok {
+			continue
+		}
+		raw, jerr := json.Marshal(val)
+		if jerr != nil {
+			return nil, jerr
+		}
+		if rv.Data == nil {
+			rv.Data = make(map[string]json.RawMessage)
+		}
+		rv.Data[rk.name] = raw
+	}
+	return rv, nil
+}
+
+func (je *jsonError) decode() (error, error) {
+	if je == nil {
+		return nil, JSONDecodeError.NewWith("null error")
+	}
+	if len(je.Errors) > 0 || len(je.Counts) > 0 {
+		group := &groupErrors{excess: je.Excess}
+		for _, child := range je.Errors {
+			decoded, err := child.decode()
+			if err != nil {
+				return nil, err
+			}
+			group.errs = append(group.errs, decoded)
+		}
+		for _, count := range je.Counts {
+			group.counts = append(group.counts, ClassCount{
+				Class: resolveClass(count.Class),
+				Count: count.Count})
+		}
+		group.assignExemplars()
+		return group, nil
+	}
+	class := resolveClass(je.Class)
+	var cause error
+	if je.Cause != nil {
+		var err error
+		cause, err = je.Cause.decode()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if class.Is(SystemError) {
+		return &remoteError{class: class, message: je.Message, err: cause}, nil
+	}
+	return je.decodeError(class, cause)
+}
+
+func (je *jsonError) decodeError(class *ErrorClass, cause error) (
+	*Error, error) {
+	if cause == nil {
+		cause = &remoteError{class: SystemError, message: je.Message}
+	}
+	rv := &Error{class: class, err: cause}
+	for name, raw := range je.Data {
+		rk, ok := lookupDataKeyName(name)
+		if !ok {
+			continue
+		}
+		if rv.data == nil {
+			rv.data = make(map[DataKey]interface{})
+		}
+		if string(raw) == "null" {
+			rv.data[rk.key] = nil
+			continue
+		}
+		val := reflect.New(rk.typ)
+		if err := json.Unmarshal(raw, val.Interface()); err != nil {
+			return nil, err
+		}
+		rv.data[rk.key] = val.Elem().Interface()
+	}
+	for _, frames := range je.Stacks {
+		rv.stacks = append(rv.stacks, decodedStack(frames))
+	}
+	for _, frame := range je.Exits {
+		rv.exits = append(rv.exits, decodedStack([]Frame{frame}))
+	}
+	return rv, nil
+}
+
+// MarshalJSON implements json.Marshaler. The encoding includes the path of
+// the error's class, the wrapped errors, any stacks and exits, and data stored
+// with keys registered with RegisterDataKey. Errors not generated through this
+// package are encoded with their message and the class GetClass returns for
+// them.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	je, err := newJSONError(e)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(je)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The error is reconstructed with
+// the class registered under the encoded class path. If that class isn't
+// registered, its closest registered ancestor is used instead, or
+// HierarchicalError if there isn't one. Wrapped errors that were not generated
+// through this package are reconstructed as errors with the same message that
+// GetClass and Contains still put in their original class.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var je jsonError
+	if err := json.Unmarshal(data, &je); err != nil {
+		return err
+	}
+	var cause error
+	if je.Cause != nil {
+		var err error
+		cause, err = je.Cause.decode()
+		if err != nil {
+			return err
+		}
+	}
+	rv, err := je.decodeError(resolveClass(je.Class), cause)
+	if err != nil {
+		return err
+	}
+	*e = *rv
+	return nil
+}
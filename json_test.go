@@ -0,0 +1,168 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+)
+
+var (
+	jsonStorage  = NewClass("JSON Storage")
+	jsonNotExist = jsonStorage.NewClass("Not Exist")
+	jsonRequest  = NewClass("JSON Request")
+
+	jsonCodeKey    = GenSym()
+	jsonUnknownKey = GenSym()
+)
+
+func init() {
+	RegisterDataKey("errors_test.code", jsonCodeKey, 0)
+}
+
+func roundTripJSON(t *testing.T, err error) *Error {
+	data, merr := json.Marshal(err)
+	if merr != nil {
+		t.Fatalf("unexpected error: %v", merr)
+	}
+	var decoded *Error
+	if uerr := json.Unmarshal(data, &decoded); uerr != nil {
+		t.Fatalf("unexpected error: %v", uerr)
+	}
+	return decoded
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	err := Record(jsonNotExist.NewWith("missing",
+		SetData(jsonCodeKey, 404), SetData(jsonUnknownKey, "dropped")))
+	decoded := roundTripJSON(t, err)
+
+	assert(t, decoded.Class() == jsonNotExist)
+	assert(t, jsonStorage.Contains(decoded))
+	assert(t, decoded.Message() == err.(*Error).Message())
+	assert(t, decoded.Error() == err.Error())
+	assert(t, GetData(decoded, jsonCodeKey).(int) == 404)
+	assert(t, GetData(decoded, jsonUnknownKey) == nil)
+	assert(t, len(GetFrames(decoded)[0]) == len(GetFrames(err)[0]))
+	assert(t, GetFrames(decoded)[0][0].Function ==
+		"github.com/spacemonkeygo/errors.TestJSONRoundTrip")
+	assert(t, GetExits(decoded) == GetExits(err))
+}
+
+func TestJSONWrapped(t *testing.T) {
+	err := jsonRequest.Wrap(jsonNotExist.Wrap(fmt.Errorf("reading: %w", io.EOF)))
+	decoded := roundTripJSON(t, err)
+
+	assert(t, decoded.Class() == jsonRequest)
+	assert(t, decoded.Message() == err.(*Error).Message())
+	assert(t, jsonNotExist.Contains(decoded, IncludeWrapped))
+	assert(t, EOF.Contains(decoded, IncludeWrapped))
+	assert(t, !ContextError.Contains(decoded, IncludeWrapped))
+	assert(t, GetClass(WrappedErr(WrappedErr(decoded))) == SystemError)
+}
+
+func TestJSONContext(t *testing.T) {
+	err := jsonRequest.Wrapf(Annotate(jsonNotExist.New("missing"), "loading"),
+		"handling %s", "/index")
+	decoded := roundTripJSON(t, err)
+
+	assert(t, decoded.Message() == err.(*Error).Message())
+	assert(t, decoded.Error() == err.Error())
+}
+
+func TestJSONUnknownClass(t *testing.T) {
+	data := []byte(`{"class":"Error/JSON Storage/Gone/Away",` +
+		`"cause":{"class":"System Error/Unknown","message":"missing"}}`)
+	var decoded Error
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert(t, decoded.Class() == jsonStorage)
+	assert(t, decoded.Message() == "JSON Storage: missing")
+	assert(t, GetClass(decoded.WrappedErr()) == SystemError)
+
+	data = []byte(`{"class":"Elsewhere","cause":{"class":"Elsewhere",` +
+		`"message":"missing"}}`)
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert(t, decoded.Class() == HierarchicalError)
+	assert(t, HierarchicalError.Contains(decoded.WrappedErr()))
+}
+
+func TestJSONGroup(t *testing.T) {
+	errs := NewBoundedErrorGroup(2)
+	errs.Add(jsonNotExist.New("missing"))
+	errs.Add(io.EOF)
+	errs.Add(io.EOF)
+	err := errs.Finalize()
+	decoded := roundTripJSON(t, err)
+
+	assert(t, decoded.Class() == ErrorGroupError)
+	assert(t, decoded.Message() == err.(*Error).Message())
+	assert(t, jsonNotExist.Contains(decoded, IncludeWrapped))
+	assert(t, EOF.Contains(decoded, IncludeWrapped))
+	assert(t, len(GetGroupErrors(decoded)) == 2)
+}
+
+func TestJSONSummarizingGroup(t *testing.T) {
+	errs := NewSummarizingErrorGroup(1)
+	errs.Add(jsonNotExist.New("missing"))
+	errs.Add(jsonNotExist.New("missing"))
+	errs.Add(io.EOF)
+	err := errs.Finalize()
+	decoded := roundTripJSON(t, err)
+
+	assert(t, decoded.Message() == err.(*Error).Message())
+	counts := GetClassCounts(decoded)
+	assert(t, len(counts) == 2)
+	assert(t, counts[0].Class == jsonNotExist && counts[0].Count == 2)
+	assert(t, len(counts[0].Exemplars) == 1)
+}
+
+func TestRegisterDataKeyNilPrototype(t *testing.T) {
+	defer func() {
+		assert(t, recover() != nil)
+		_, ok := lookupDataKeyName("errors_test.nilPrototype")
+		assert(t, !ok)
+	}()
+	RegisterDataKey("errors_test.nilPrototype", GenSym(), nil)
+}
+
+func TestJSONMalformed(t *testing.T) {
+	for _, data := range []string{
+		`{"class":"Error","cause":{"class":"System Error","errors":[null]}}`,
+		`{"class":"Error","cause":{"class":"System Error",` +
+			`"errors":[{"class":"System Error","message":"ok"},null]}}`,
+		`{"class":"Error","cause":{"class":"Error","cause":` +
+			`{"class":"System Error","errors":[null]}}}`,
+	} {
+		var decoded Error
+		err := json.Unmarshal([]byte(data), &decoded)
+		assert(t, JSONDecodeError.Contains(err))
+	}
+}
+
+func TestJSONGroupNoCaptureStack(t *testing.T) {
+	errs := NewErrorGroupNoCaptureStack()
+	errs.Add(jsonNotExist.New("missing"))
+	errs.Add(io.EOF)
+	decoded := roundTripJSON(t, errs.Finalize())
+
+	assert(t, decoded.Class() == ErrorGroupNoCaptureStackError)
+	assert(t, len(GetGroupErrors(decoded)) == 2)
+}
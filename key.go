@@ -0,0 +1,61 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package errors
+
+// Key is a DataKey for values of type T. Values set with a Key are ordinary
+// error data, found through the same hierarchical lookup as GetData, so a Key
+// and the untyped API can be mixed freely through Key.DataKey.
+type Key[T any] struct {
+	key DataKey
+}
+
+// NewKey generates a brand new, never-before-seen Key, like GenSym.
+func NewKey[T any]() Key[T] { return Key[T]{key: GenSym()} }
+
+// NewNamedKey is like NewKey, but names the Key's DataKey, like GenSymNamed.
+func NewNamedKey[T any](name string) Key[T] {
+	return Key[T]{key: GenSymNamed(name)}
+}
+
+// DataKey returns the untyped DataKey the receiver stores its values with.
+func (k Key[T]) DataKey() DataKey { return k.key }
+
+// Set returns an ErrorOption that stores value under the receiver.
+func (k Key[T]) Set(value T) ErrorOption { return SetData(k.key, value) }
+
+// Get returns the value stored under the receiver for err, along with whether
+// there was one of type T. See GetData.
+func (k Key[T]) Get(err error, opts ...EquivalenceOption) (value T, ok bool) {
+	value, ok = GetData(err, k.key, opts...).(T)
+	return value, ok
+}
+
+// GetOr is like Get, but returns def if there is no value of type T.
+func (k Key[T]) GetOr(err error, def T, opts ...EquivalenceOption) T {
+	if value, ok := k.Get(err, opts...); ok {
+		return value
+	}
+	return def
+}
+
+// GetClass returns the value stored under the receiver for the error class
+// ec, along with whether there was one of type T. See ErrorClass.GetData.
+func (k Key[T]) GetClass(ec *ErrorClass) (value T, ok bool) {
+	value, ok = ec.GetData(k.key).(T)
+	return value, ok
+}
@@ -0,0 +1,51 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package errors
+
+import (
+	"testing"
+)
+
+var (
+	keyRetries = NewKey[int]()
+	keyName    = NewNamedKey[string]("name")
+	keyClass   = NewClass("Key Class", keyRetries.Set(3))
+)
+
+func TestKey(t *testing.T) {
+	err := keyClass.NewWith("failed", keyName.Set("name"))
+
+	retries, ok := keyRetries.Get(err)
+	assert(t, ok && retries == 3)
+	name, ok := keyName.Get(err)
+	assert(t, ok && name == "name")
+	assert(t, GetData(err, keyName.DataKey()) == "name")
+	assert(t, keyName.DataKey().Name() == "name")
+
+	retries, ok = keyRetries.GetClass(keyClass)
+	assert(t, ok && retries == 3)
+	_, ok = keyName.GetClass(keyClass)
+	assert(t, !ok)
+
+	err = HierarchicalError.NewWith("failed",
+		SetData(keyRetries.DataKey(), "not an int"))
+	_, ok = keyRetries.Get(err)
+	assert(t, !ok)
+	assert(t, keyRetries.GetOr(err, 5) == 5)
+	assert(t, keyName.GetOr(nil, "default") == "default")
+}
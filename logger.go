@@ -0,0 +1,126 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+import (
+	"sync/atomic"
+)
+
+var (
+	loggerKey = GenSym()
+
+	globalLogger atomic.Value
+)
+
+// Level is the severity of a log line.
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Field is a key/value pair attached to a log line.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// The keys of fields this package attaches to log lines.
+const (
+	// ErrorField holds the error the line is about.
+	ErrorField = "error"
+	// StackField holds the formatted stack of the goroutine that logged the
+	// line.
+	StackField = "stack"
+	// GroupField holds the name of the LoggingErrorGroup that logged the line.
+	GroupField = "group"
+)
+
+// Logger is the interface errors are logged through, by LogWithStack,
+// LoggingErrorGroup and errors of classes with LogOnCreation. Loggers must be
+// safe to use from multiple goroutines.
+type Logger interface {
+	// Log logs msg at the given level with the given fields attached.
+	Log(level Level, msg string, fields ...Field)
+}
+
+// loggerHolder lets a Logger of any type be stored in globalLogger.
+type loggerHolder struct{ Logger }
+
+// logMethodLogger is the default Logger. It writes the message followed by a
+// blank line and the StackField if present through LogMethod, and omits other
+// fields, matching the output of earlier releases. Errors logged on creation
+// are given to it with their Error() as the message, as before.
+type logMethodLogger struct{}
+
+func (logMethodLogger) Log(level Level, msg string, fields ...Field) {
+	for _, field := range fields {
+		if field.Key == StackField {
+			LogMethod("%s\n\n%s", msg, field.Value)
+			return
+		}
+	}
+	LogMethod("%s", msg)
+}
+
+// SetLogger changes the Logger used for errors that don't have one set with
+// LogTo. Passing nil restores the default Logger, which writes through
+// LogMethod.
+func SetLogger(l Logger) {
+	globalLogger.Store(loggerHolder{Logger: l})
+}
+
+// GetLogger returns the Logger used for errors that don't have one set with
+// LogTo.
+func GetLogger() Logger {
+	if holder, ok := globalLogger.Load().(loggerHolder); ok &&
+		holder.Logger != nil {
+		return holder.Logger
+	}
+	return logMethodLogger{}
+}
+
+// LogTo tells the error class and its descendents to log through the given
+// Logger instead of the one set with SetLogger.
+func LogTo(l Logger) ErrorOption {
+	return SetData(loggerKey, l)
+}
+
+// loggerFor returns the Logger to log err with.
+func loggerFor(err error) Logger {
+	if l, ok := GetData(err, loggerKey).(Logger); ok {
+		return l
+	}
+	return GetLogger()
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+var panicValue = GenSym()
+
+// GetPanicValue returns the value a panic caught by CatchPanic or Go was
+// called with, or nil if err didn't come from a panic.
+func GetPanicValue(err error) interface{} {
+	return GetData(err, panicValue)
+}
+
+// newPanicError turns the recovered panic value r into a PanicError. It must
+// be called from the deferred function that recovered r so that the stack of
+// the panic can be captured.
+func newPanicError(r interface{}) *Error {
+	cause, ok := r.(error)
+	if !ok {
+		cause = errors.New(fmt.Sprint(r))
+	}
+	class := panicClass(r)
+	if cast, ok := cause.(*Error); ok && cast.class.Is(class) {
+		// it's already a panic error, so keep its stack but record the value.
+		return WithData(cast, SetData(panicValue, r)).(*Error)
+	}
+
+	rv := &Error{err: cause, class: class,
+		data: map[DataKey]interface{}{panicValue: r}}
+	if boolWrapper(rv.GetData(captureStack), false) {
+		rv.stacks = []*stackTrace{rv.capturePanicStack()}
+	}
+	rv.logCreation()
+	return rv
+}
+
+// panicClass returns the class a panic with value r should have.
+func panicClass(r interface{}) *ErrorClass {
+	if _, ok := r.(*runtime.TypeAssertionError); ok {
+		return TypeAssertionPanicError
+	}
+	rerr, ok := r.(runtime.Error)
+	if !ok {
+		return PanicError
+	}
+	msg := rerr.Error()
+	switch {
+	case strings.Contains(msg, "nil pointer dereference"):
+		return NilDereferenceError
+	case strings.Contains(msg, "index out of range"):
+		return IndexOutOfRangeError
+	case strings.Contains(msg, "slice bounds out of range"):
+		return SliceBoundsError
+	case strings.Contains(msg, "integer divide by zero"):
+		return DivideByZeroError
+	case strings.Contains(msg, "assignment to entry in nil map"):
+		return NilMapAssignmentError
+	}
+	return RuntimePanicError
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+import (
+	"sort"
+	"sync"
+)
+
+// registry holds every error class by path. If more than one class is created
+// with the same path, the first one is kept and the path is recorded in
+// duplicates.
+var registry = struct {
+	mu         sync.RWMutex
+	classes    map[string]*ErrorClass
+	duplicates map[string]int
+}{
+	classes:    make(map[string]*ErrorClass),
+	duplicates: make(map[string]int),
+}
+
+func registerClass(ec *ErrorClass) *ErrorClass {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, exists := registry.classes[ec.path]; exists {
+		registry.duplicates[ec.path]++
+		return ec
+	}
+	registry.classes[ec.path] = ec
+	return ec
+}
+
+// LookupClass returns the error class registered under the given fully
+// qualified path, such as "Error/Storage/Not Exist", or nil if there is none.
+// If more than one class was created with the same path, the first one
+// created is returned.
+func LookupClass(path string) *ErrorClass {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.classes[path]
+}
+
+// AllClasses returns every registered error class, sorted by path.
+func AllClasses() []*ErrorClass {
+	registry.mu.RLock()
+	classes := make([]*ErrorClass, 0, len(registry.classes))
+	for _, ec := range registry.classes {
+		classes = append(classes, ec)
+	}
+	registry.mu.RUnlock()
+	sort.Slice(classes, func(i, j int) bool {
+		return classes[i].path < classes[j].path
+	})
+	return classes
+}
+
+// DuplicateClasses returns the sorted paths that more than one error class has
+// been created with. Only the first class created with each of these paths
+// can be found with LookupClass.
+func DuplicateClasses() []string {
+	registry.mu.RLock()
+	paths := make([]string, 0, len(registry.duplicates))
+	for path := range registry.duplicates {
+		paths = append(paths, path)
+	}
+	registry.mu.RUnlock()
+	sort.Strings(paths)
+	return paths
+}
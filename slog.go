@@ -0,0 +1,90 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package errors
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// LogValue implements slog.LogValuer. The error is logged as a group with the
+// class path, the message, the wrapped error as "cause", the exits and stack
+// if any, and the non-nil data AllData returns for the error.
+func (e *Error) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("class", e.class.path),
+		slog.String("message", e.Message()),
+	}
+	if cause, ok := e.err.(*Error); ok {
+		attrs = append(attrs, slog.Any("cause", cause))
+	} else {
+		attrs = append(attrs, slog.String("cause", e.err.Error()))
+	}
+	if exits := e.Exits(); exits != "" {
+		attrs = append(attrs, slog.Any("exits", strings.Split(exits, "\n")))
+	}
+	if stack := e.Stack(); stack != "" {
+		attrs = append(attrs, slog.Any("stack", strings.Split(stack, "\n")))
+	}
+	for _, entry := range e.allData() {
+		if entry.Value != nil {
+			attrs = append(attrs, slog.Any(entry.Name, entry.Value))
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// slogLogger is a Logger that logs through a slog.Handler.
+type slogLogger struct {
+	handler slog.Handler
+}
+
+// NewSlogLogger returns a Logger that logs through the given slog.Handler,
+// for use with SetLogger or LogTo. Fields become attributes, so errors in the
+// ErrorField are logged with their LogValue.
+func NewSlogLogger(h slog.Handler) Logger {
+	return slogLogger{handler: h}
+}
+
+func (l slogLogger) Log(level Level, msg string, fields ...Field) {
+	ctx := context.Background()
+	slevel := slogLevel(level)
+	if !l.handler.Enabled(ctx, slevel) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), slevel, msg, 0)
+	for _, field := range fields {
+		r.AddAttrs(slog.Any(field.Key, field.Value))
+	}
+	_ = l.handler.Handle(ctx, r)
+}
+
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case DebugLevel:
+		return slog.LevelDebug
+	case InfoLevel:
+		return slog.LevelInfo
+	case WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
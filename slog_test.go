@@ -0,0 +1,84 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestLogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	err := Record(jsonRequest.Wrap(jsonNotExist.Wrap(io.EOF),
+		SetData(jsonCodeKey, 404)))
+	logger.Error("failed", "error", err)
+
+	var line struct {
+		Error struct {
+			Class   string
+			Message string
+			Cause   struct {
+				Class string
+				Cause string
+			}
+			Exits []string
+			Stack []string
+			Code  int `json:"errors_test.code"`
+		}
+	}
+	if jerr := json.Unmarshal(buf.Bytes(), &line); jerr != nil {
+		t.Fatalf("unexpected error: %v", jerr)
+	}
+	assert(t, line.Error.Class == "Error/JSON Request")
+	assert(t, line.Error.Message == "JSON Request: Not Exist: EOF")
+	assert(t, line.Error.Cause.Class == "Error/JSON Storage/Not Exist")
+	assert(t, line.Error.Cause.Cause == "EOF")
+	assert(t, len(line.Error.Exits) == 1)
+	assert(t, len(line.Error.Stack) > 0)
+	assert(t, line.Error.Code == 404)
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.NewJSONHandler(&buf,
+		&slog.HandlerOptions{Level: slog.LevelWarn}))
+	class := NewClass("Slog Logged", LogOnCreation(), LogTo(logger))
+	class.New("testing")
+
+	var line struct {
+		Level string
+		Msg   string
+		Error struct{ Class string }
+		Stack string
+	}
+	if jerr := json.Unmarshal(buf.Bytes(), &line); jerr != nil {
+		t.Fatalf("unexpected error: %v", jerr)
+	}
+	assert(t, line.Level == "ERROR")
+	assert(t, line.Msg == "Slog Logged: testing")
+	assert(t, line.Error.Class == "Error/Slog Logged")
+	assert(t, line.Stack != "")
+
+	buf.Reset()
+	logger.Log(InfoLevel, "ignored")
+	assert(t, buf.Len() == 0)
+}
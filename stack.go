@@ -0,0 +1,448 @@
+// Copyright (C) 2020 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	stackDepth   = GenSym()
+	stackSkip    = GenSym()
+	stackInclude = GenSym()
+	stackExclude = GenSym()
+)
+
+// StackDepth limits the number of frames captured in the stacks of the error,
+// or of errors of the class and its descendents. It overrides
+// Config.Stackdepth.
+func StackDepth(depth int) ErrorOption {
+	return SetData(stackDepth, depth)
+}
+
+// SkipFrames skips the given number of innermost frames when capturing the
+// stack of the error, or of errors of the class and its descendents. This is
+// useful for errors created inside helper functions. It overrides
+// Config.Stackskip.
+func SkipFrames(count int) ErrorOption {
+	return SetData(stackSkip, count)
+}
+
+// IncludePackages restricts the frames printed in the backtraces of the error,
+// or of errors of the class and its descendents, to frames from packages
+// whose import path starts with one of the given prefixes. It overrides
+// Config.Stackinclude.
+func IncludePackages(prefixes ...string) ErrorOption {
+	return SetData(stackInclude, prefixes)
+}
+
+// ExcludePackages hides frames from packages whose import path starts with one
+// of the given prefixes from the backtraces of the error, or of errors of the
+// class and its descendents. It overrides Config.Stackexclude.
+func ExcludePackages(prefixes ...string) ErrorOption {
+	return SetData(stackExclude, prefixes)
+}
+
+func intWrapper(val interface{}, default_value int) int {
+	rv, ok := val.(int)
+	if ok {
+		return rv
+	}
+	return default_value
+}
+
+func prefixesWrapper(val interface{}, default_value []string) []string {
+	rv, ok := val.([]string)
+	if ok {
+		return rv
+	}
+	return default_value
+}
+
+// prefixCache remembers the parsed form of a comma separated prefix setting,
+// so that it is only split again when the setting changes.
+type prefixCache struct {
+	mtx      sync.Mutex
+	raw      string
+	prefixes []string
+}
+
+var (
+	stackincludeCache prefixCache
+	stackexcludeCache prefixCache
+)
+
+// parse returns the non-empty, trimmed prefixes in raw.
+func (c *prefixCache) parse(raw string) []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if raw != c.raw {
+		var prefixes []string
+		for _, prefix := range strings.Split(raw, ",") {
+			// an empty prefix would match every package.
+			if prefix = strings.TrimSpace(prefix); prefix != "" {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		c.raw, c.prefixes = raw, prefixes
+	}
+	return c.prefixes
+}
+
+// hasPrefix returns true if s starts with any of the given prefixes.
+func hasPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Frame describes a single function call recorded in a stack trace or as an
+// exit.
+type Frame struct {
+	// Function is the fully qualified function name, such as
+	// "github.com/spacemonkeygo/errors.(*ErrorClass).New".
+	Function string `json:"function"`
+	// File is the full path of the source file.
+	File string `json:"file"`
+	// Line is the line number in File.
+	Line int `json:"line"`
+	// Package is the import path of the package Function belongs to.
+	Package string `json:"package"`
+	// PC is the program counter of the call. It is zero for frames decoded
+	// from another process.
+	PC uintptr `json:"-"`
+}
+
+// String returns a human readable form of the frame.
+func (f Frame) String() string {
+	if f.Function == "" {
+		return "unknown.unknown:0"
+	}
+	return fmt.Sprintf("%s:%s:%d", f.Function, filepath.Base(f.File), f.Line)
+}
+
+// newFrame converts a runtime.Frame into a Frame.
+func newFrame(f runtime.Frame) Frame {
+	return Frame{
+		Function: f.Function,
+		File:     f.File,
+		Line:     f.Line,
+		Package:  funcPackage(f.Function),
+		PC:       f.PC,
+	}
+}
+
+// funcPackage returns the package import path of a fully qualified function
+// name.
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
+
+// framesOf symbolizes the given pcs as returned by runtime.Callers. Inlined
+// calls are expanded into their own frames.
+func framesOf(pcs []uintptr) []Frame {
+	if len(pcs) == 0 {
+		return nil
+	}
+	frames := make([]Frame, 0, len(pcs))
+	iter := runtime.CallersFrames(pcs)
+	for {
+		f, more := iter.Next()
+		frames = append(frames, newFrame(f))
+		if !more {
+			return frames
+		}
+	}
+}
+
+// callerState records the pc of the caller depth frames up as a single frame
+// stack.
+func callerState(depth int) *stackTrace {
+	var pcs [1]uintptr
+	return internStack(pcs[:runtime.Callers(depth+1, pcs[:])])
+}
+
+// stackTrace is a captured stack. Stacks are immutable once captured, so
+// identical stacks are interned and shared between errors. Symbolization is
+// deferred until the frames are first needed and then remembered, as is the
+// unfiltered text of the stack.
+type stackTrace struct {
+	pcs    []uintptr
+	once   sync.Once
+	frames []Frame
+
+	textOnce sync.Once
+	text     string
+}
+
+// Frames returns the symbolized frames of the stack. The frames are shared by
+// every error with this stack, so they must not be modified.
+func (s *stackTrace) Frames() []Frame {
+	s.once.Do(func() { s.frames = framesOf(s.pcs) })
+	return s.frames
+}
+
+// String returns the frames of the stack, one per line.
+func (s *stackTrace) String() string {
+	s.textOnce.Do(func() {
+		frames := s.Frames()
+		lines := make([]string, 0, len(frames))
+		for _, f := range frames {
+			lines = append(lines, f.String())
+		}
+		s.text = strings.Join(lines, "\n")
+	})
+	return s.text
+}
+
+// exitFrame returns the frame of an exit recorded by callerState.
+func (s *stackTrace) exitFrame() Frame {
+	frames := s.Frames()
+	if len(frames) == 0 {
+		return Frame{}
+	}
+	return frames[0]
+}
+
+// decodedStack returns a stackTrace for frames that were already symbolized,
+// possibly by a different process.
+func decodedStack(frames []Frame) *stackTrace {
+	st := &stackTrace{frames: frames}
+	st.once.Do(func() {})
+	return st
+}
+
+// stackTable holds the interned stacks, keyed by a hash of their pcs. It is
+// bounded by Config.Stackinternsize; once full, new stacks are simply not
+// shared.
+var stackTable = struct {
+	mu     sync.RWMutex
+	stacks map[uint64]*stackTrace
+}{stacks: make(map[uint64]*stackTrace)}
+
+func hashPCs(pcs []uintptr) uint64 {
+	// FNV-1a
+	h := uint64(14695981039346656037)
+	for _, pc := range pcs {
+		h ^= uint64(pc)
+		h *= 1099511628211
+	}
+	return h
+}
+
+func equalPCs(a, b []uintptr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// internStack returns the shared stackTrace for pcs, creating it if needed.
+// pcs is copied if it has to be retained.
+func internStack(pcs []uintptr) *stackTrace {
+	h := hashPCs(pcs)
+	stackTable.mu.RLock()
+	st, ok := stackTable.stacks[h]
+	stackTable.mu.RUnlock()
+	if ok && equalPCs(st.pcs, pcs) {
+		return st
+	}
+
+	st = &stackTrace{pcs: append([]uintptr(nil), pcs...)}
+	stackTable.mu.Lock()
+	defer stackTable.mu.Unlock()
+	if existing, ok := stackTable.stacks[h]; ok {
+		if equalPCs(existing.pcs, pcs) {
+			return existing
+		}
+		// hash collision. keep the existing entry.
+		return st
+	}
+	if len(stackTable.stacks) < Config.Stackinternsize {
+		stackTable.stacks[h] = st
+	}
+	return st
+}
+
+// getStack captures the current stack, skipping skip frames and keeping at most
+// depth frames.
+func getStack(skip, depth int) *stackTrace {
+	var buf [256]uintptr
+	pcs := buf[:]
+	if depth > len(buf) {
+		pcs = make([]uintptr, depth)
+	} else if depth >= 0 {
+		pcs = buf[:depth]
+	}
+	amount := runtime.Callers(skip+1, pcs)
+	return internStack(pcs[:amount])
+}
+
+// captureStack captures the current stack for the receiver starting depth
+// frames up, further adjusted by the receiver's skip and depth settings.
+func (e *Error) captureStack(depth int) *stackTrace {
+	return getStack(depth+1+intWrapper(e.GetData(stackSkip), Config.Stackskip),
+		intWrapper(e.GetData(stackDepth), Config.Stackdepth))
+}
+
+// getPanicStack captures the stack of a panicking goroutine from within a
+// deferred function, starting at the frame that panicked. Frames of the
+// deferred function and the runtime's panic machinery are dropped, then skip
+// frames are skipped and at most depth frames are kept. If the goroutine isn't
+// panicking, the stack is captured as in getStack.
+func getPanicStack(skip, depth int) *stackTrace {
+	var buf [256]uintptr
+	pcs := buf[:]
+	if depth > len(buf)/2 {
+		pcs = make([]uintptr, 2*depth)
+	}
+	pcs = trimPanicFrames(pcs[:runtime.Callers(2, pcs)])
+	if skip > len(pcs) {
+		skip = len(pcs)
+	}
+	pcs = pcs[skip:]
+	if depth >= 0 && depth < len(pcs) {
+		pcs = pcs[:depth]
+	}
+	return internStack(pcs)
+}
+
+// trimPanicFrames drops everything up to and including the innermost
+// runtime.gopanic frame, along with the runtime frames that called it, such as
+// runtime.sigpanic or runtime.panicIndex. pcs is returned unchanged if it
+// contains no runtime.gopanic frame.
+func trimPanicFrames(pcs []uintptr) []uintptr {
+	for i, pc := range pcs {
+		if pcFunction(pc) != "runtime.gopanic" {
+			continue
+		}
+		i++
+		for i < len(pcs) && strings.HasPrefix(pcFunction(pcs[i]), "runtime.") {
+			i++
+		}
+		return pcs[i:]
+	}
+	return pcs
+}
+
+// pcFunction returns the name of the function containing the return address
+// pc.
+func pcFunction(pc uintptr) string {
+	fn := runtime.FuncForPC(pc - 1)
+	if fn == nil {
+		return ""
+	}
+	return fn.Name()
+}
+
+// capturePanicStack captures the stack of a panicking goroutine for the
+// receiver, adjusted by the receiver's skip and depth settings. See
+// getPanicStack.
+func (e *Error) capturePanicStack() *stackTrace {
+	return getPanicStack(intWrapper(e.GetData(stackSkip), Config.Stackskip),
+		intWrapper(e.GetData(stackDepth), Config.Stackdepth))
+}
+
+// stackFilters returns the package prefixes that the receiver's backtraces are
+// limited to and the ones that are hidden from them.
+func (e *Error) stackFilters() (include, exclude []string) {
+	return prefixesWrapper(e.GetData(stackInclude),
+			stackincludeCache.parse(Config.Stackinclude)),
+		prefixesWrapper(e.GetData(stackExclude),
+			stackexcludeCache.parse(Config.Stackexclude))
+}
+
+// filterFrames returns the frames that should be printed given the prefixes
+// from stackFilters.
+func filterFrames(frames []Frame, include, exclude []string) []Frame {
+	if len(include) == 0 && len(exclude) == 0 {
+		return frames
+	}
+	filtered := make([]Frame, 0, len(frames))
+	for _, f := range frames {
+		if len(include) > 0 && !hasPrefix(f.Package, include) {
+			continue
+		}
+		if hasPrefix(f.Package, exclude) {
+			continue
+		}
+		filtered = append(filtered, f)
+	}
+	return filtered
+}
+
+// Frames will return the stacks associated with the error, if any, as
+// structured frames. The first stack is where the error was created and any
+// following stacks were added with AttachStack. Unlike Stack, the frames are
+// not filtered by IncludePackages or ExcludePackages. The frames are a copy
+// and may be modified freely. You probably want the package-level GetFrames.
+func (e *Error) Frames() [][]Frame {
+	if len(e.stacks) == 0 {
+		return nil
+	}
+	stacks := make([][]Frame, 0, len(e.stacks))
+	for _, stack := range e.stacks {
+		// stacks are shared between errors, so hand out a copy.
+		stacks = append(stacks, append([]Frame(nil), stack.Frames()...))
+	}
+	return stacks
+}
+
+// GetFrames will return the stacks associated with the error if any are found.
+func GetFrames(err error) [][]Frame {
+	cast, ok := err.(*Error)
+	if !ok {
+		return nil
+	}
+	return cast.Frames()
+}
+
+// ExitFrames will return the exits recorded on the error, if any, as
+// structured frames. You probably want the package-level GetExitFrames.
+func (e *Error) ExitFrames() []Frame {
+	if len(e.exits) == 0 {
+		return nil
+	}
+	exits := make([]Frame, 0, len(e.exits))
+	for _, ex := range e.exits {
+		exits = append(exits, ex.exitFrame())
+	}
+	return exits
+}
+
+// GetExitFrames will return the exits recorded on the error if any are found.
+func GetExitFrames(err error) []Frame {
+	cast, ok := err.(*Error)
+	if !ok {
+		return nil
+	}
+	return cast.ExitFrames()
+}
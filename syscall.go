@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// +build !appengine
+// +build !appengine,!plan9
 
 package errors
 
@@ -20,7 +20,57 @@ import (
 	"syscall"
 )
 
-func isErrnoError(err error) bool {
-	_, ok := err.(syscall.Errno)
-	return ok
+// errnoClasses maps the errno values that have a class of their own to it. It
+// is built in init because some platforms give several of these names the
+// same value, such as EEXIST and ENOTEMPTY on AIX, which a map literal can't
+// express. The first class listed for a value wins.
+var errnoClasses = make(map[syscall.Errno]*ErrorClass)
+
+func init() {
+	for _, ec := range []struct {
+		errno syscall.Errno
+		class *ErrorClass
+	}{
+		{syscall.EPERM, NotPermitted},
+		{syscall.ENOENT, NoEntry},
+		{syscall.EINTR, Interrupted},
+		{syscall.EIO, InputOutput},
+		{syscall.EBADF, BadFileDescriptor},
+		{syscall.EAGAIN, TryAgain},
+		{syscall.ENOMEM, NoMemory},
+		{syscall.EACCES, AccessDenied},
+		{syscall.EEXIST, FileExists},
+		{syscall.ENOTDIR, NotDirectory},
+		{syscall.EISDIR, IsDirectory},
+		{syscall.EINVAL, InvalidArgument},
+		{syscall.EMFILE, TooManyOpenFiles},
+		{syscall.ENOSPC, NoSpace},
+		{syscall.EROFS, ReadOnlyFileSystem},
+		{syscall.EPIPE, BrokenPipe},
+		{syscall.ENOTEMPTY, DirectoryNotEmpty},
+		{syscall.EADDRINUSE, AddrInUse},
+		{syscall.EADDRNOTAVAIL, AddrNotAvailable},
+		{syscall.ENETUNREACH, NetworkUnreachable},
+		{syscall.ECONNABORTED, ConnAborted},
+		{syscall.ECONNRESET, ConnReset},
+		{syscall.ETIMEDOUT, TimedOut},
+		{syscall.ECONNREFUSED, ConnRefused},
+		{syscall.EHOSTUNREACH, HostUnreachable},
+	} {
+		if _, exists := errnoClasses[ec.errno]; !exists {
+			errnoClasses[ec.errno] = ec.class
+		}
+	}
+}
+
+// findErrnoClass returns the class of err if it is a syscall.Errno, or nil.
+func findErrnoClass(err error) *ErrorClass {
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		return nil
+	}
+	if class, ok := errnoClasses[errno]; ok {
+		return class
+	}
+	return ErrnoError
 }
@@ -12,10 +12,10 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// +build appengine
+// +build appengine plan9
 
 package errors
 
-func isErrnoError(err error) bool {
-	return false
+func findErrnoClass(err error) *ErrorClass {
+	return nil
 }
@@ -15,49 +15,100 @@
 package errors
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 )
 
 var (
-	// Change this method if you want errors to log somehow else
+	classCountsKey = GenSym()
+
+	// Change this method if you want errors to log somehow else. It is used
+	// by the default Logger; see SetLogger.
 	LogMethod = log.Printf
 
-	ErrorGroupError               = NewClass("Error Group Error")
-	ErrorGroupNoCaptureStackError = NewClass("Error Group Error", NoCaptureStack())
+	ErrorGroupError = NewClass("Error Group Error")
+	// ErrorGroupNoCaptureStackError prints the same as ErrorGroupError, but is
+	// registered under its own path so that decoded errors keep their class.
+	ErrorGroupNoCaptureStackError = HierarchicalError.newClass(
+		"Error Group Error", "Error/Error Group Error (No Capture Stack)",
+		[]ErrorOption{NoCaptureStack()})
 )
 
 // LogWithStack will log the given messages with the current stack
 func LogWithStack(messages ...interface{}) {
+	logWithStack(GetLogger(),
+		strings.TrimSuffix(fmt.Sprintln(messages...), "\n"))
+}
+
+// logWithStack logs msg with the current stack through l.
+func logWithStack(l Logger, msg string, fields ...Field) {
 	buf := make([]byte, Config.Stacklogsize)
 	buf = buf[:runtime.Stack(buf, false)]
-	LogMethod("%s\n%s", fmt.Sprintln(messages...), buf)
+	l.Log(ErrorLevel, msg, append(fields, Field{StackField, string(buf)})...)
 }
 
 // CatchPanic can be used to catch panics and turn them into errors. See the
-// example.
+// example. The resulting error's stack starts where the panic happened, the
+// original panic value is available through GetPanicValue, and runtime.Error
+// panics are classified into the RuntimePanicError subclasses.
 func CatchPanic(err_ref *error) {
 	r := recover()
 	if r == nil {
 		return
 	}
-	err, ok := r.(error)
-	if ok {
-		*err_ref = PanicError.Wrap(err)
-		return
+	*err_ref = newPanicError(r)
+}
+
+// Go runs fn in a new goroutine. If fn returns an error, or panics, handler is
+// called with the error, or with the PanicError CatchPanic would have made.
+// PanicErrors also get the stack of the goroutine that called Go attached.
+// handler is not called if fn returns nil, so an ErrorGroup's Add method works
+// as a handler. handler may be nil, in which case errors are dropped.
+func Go(fn func() error, handler func(error)) {
+	go runTask(fn, handler, getStack(2, Config.Stackdepth))
+}
+
+// runTask runs fn for Go, attaching spawner to any PanicError.
+func runTask(fn func() error, handler func(error), spawner *stackTrace) {
+	err := callTask(fn, spawner)
+	if err != nil && handler != nil {
+		handler(err)
 	}
-	*err_ref = PanicError.New("%v", r)
+}
+
+func callTask(fn func() error, spawner *stackTrace) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		cast := newPanicError(r)
+		cast.attachStack(spawner)
+		err = cast
+	}()
+	return fn()
 }
 
 // ErrorGroup is a type for collecting errors from a bunch of independent
-// tasks. ErrorGroups are not threadsafe. See the example for usage.
+// tasks. Add and Finalize are threadsafe, but Errors must not be accessed
+// while other goroutines may be calling them. See the example for usage, and
+// Group for running the tasks in goroutines.
 type ErrorGroup struct {
 	Errors         []error
+	mu             sync.Mutex
 	noCaptureStack bool
 	limit          int
 	excess         int
+	summarize      bool
+	exemplars      int
+	counts         classCounter
 }
 
 // NewErrorGroup makes a new ErrorGroup
@@ -79,11 +130,116 @@ func NewBoundedErrorGroup(limit int) *ErrorGroup {
 	}
 }
 
+// NewSummarizingErrorGroup makes a new ErrorGroup that buckets errors by
+// class, as determined by GetClass. It keeps at most exemplars errors of each
+// class and counts the rest. If more than one error is added, the finalized
+// error's message is a table of the classes with their counts and a sample
+// message instead of every message. See ClassCounts and GetClassCounts for
+// the counts.
+func NewSummarizingErrorGroup(exemplars int) *ErrorGroup {
+	return &ErrorGroup{
+		summarize: true,
+		exemplars: exemplars,
+	}
+}
+
+// ClassCount is the number of errors of one class seen by an ErrorGroup.
+type ClassCount struct {
+	Class *ErrorClass
+	Count int
+	// Exemplars holds the errors of the class that were kept.
+	Exemplars []error
+}
+
+// classCounter tallies errors by class.
+type classCounter struct {
+	counts  []ClassCount
+	classes map[*ErrorClass]int
+}
+
+// add counts err, keeping it if there are fewer than exemplars errors kept
+// for its class. It returns whether err was kept.
+func (c *classCounter) add(err error, exemplars int) bool {
+	class := GetClass(err)
+	if c.classes == nil {
+		c.classes = make(map[*ErrorClass]int)
+	}
+	i, ok := c.classes[class]
+	if !ok {
+		i = len(c.counts)
+		c.classes[class] = i
+		c.counts = append(c.counts, ClassCount{Class: class})
+	}
+	c.counts[i].Count++
+	if len(c.counts[i].Exemplars) < exemplars {
+		c.counts[i].Exemplars = append(c.counts[i].Exemplars, err)
+		return true
+	}
+	return false
+}
+
+// sorted returns a copy of the counts, most common class first.
+func (c *classCounter) sorted() []ClassCount {
+	counts := make([]ClassCount, 0, len(c.counts))
+	for _, count := range c.counts {
+		count.Exemplars = append([]error(nil), count.Exemplars...)
+		counts = append(counts, count)
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Class.Path() < counts[j].Class.Path()
+	})
+	return counts
+}
+
+// ClassCounts returns the number of errors of each class added so far, most
+// common class first. It returns nil unless the ErrorGroup was made with
+// NewSummarizingErrorGroup.
+func (e *ErrorGroup) ClassCounts() []ClassCount {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.summarize {
+		return nil
+	}
+	return e.counts.sorted()
+}
+
+// GetClassCounts returns the number of errors of each class in an error
+// finalized by an ErrorGroup made with NewSummarizingErrorGroup or by a
+// LoggingErrorGroup, or nil if err isn't one.
+func GetClassCounts(err error) []ClassCount {
+	if counts, ok := GetData(err, classCountsKey).([]ClassCount); ok {
+		return append([]ClassCount(nil), counts...)
+	}
+	for err != nil {
+		if group, ok := err.(*groupErrors); ok {
+			if group.counts == nil {
+				return nil
+			}
+			return append([]ClassCount(nil), group.counts...)
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil
+}
+
 // Add is called with errors. nil errors are ignored.
 func (e *ErrorGroup) Add(err error) {
 	if err == nil {
 		return
 	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.summarize {
+		if e.counts.add(err, e.exemplars) {
+			e.Errors = append(e.Errors, err)
+		} else {
+			e.excess++
+		}
+		return
+	}
 	if e.limit > 0 && len(e.Errors) == e.limit {
 		e.excess++
 	} else {
@@ -93,37 +249,134 @@ func (e *ErrorGroup) Add(err error) {
 
 // Finalize will collate all the found errors. If no errors were found, it will
 // return nil. If one error was found, it will be returned directly. Otherwise
-// an ErrorGroupError will be returned.
+// an ErrorGroupError will be returned, which keeps the individual errors; see
+// GetGroupErrors. ErrorClass.Contains with IncludeWrapped, and the standard
+// library's errors.Is and errors.As, search the individual errors.
 func (e *ErrorGroup) Finalize() error {
-	if len(e.Errors) == 0 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.Errors) == 0 && e.excess == 0 {
 		return nil
 	}
 	if len(e.Errors) == 1 && e.excess == 0 {
 		return e.Errors[0]
 	}
-	msgs := make([]string, 0, len(e.Errors))
-	for _, err := range e.Errors {
-		msgs = append(msgs, err.Error())
-	}
-	if e.excess > 0 {
-		msgs = append(msgs, fmt.Sprintf("... and %d more.", e.excess))
-		e.excess = 0
+	group := &groupErrors{errs: e.Errors, excess: e.excess}
+	if e.summarize {
+		group.counts = e.counts.sorted()
 	}
 	e.Errors = nil
+	e.excess = 0
+	e.counts = classCounter{}
 	if e.noCaptureStack {
-		return ErrorGroupNoCaptureStackError.New(strings.Join(msgs, "\n"))
+		return ErrorGroupNoCaptureStackError.Wrap(group)
 	}
 
-	return ErrorGroupError.New(strings.Join(msgs, "\n"))
+	return ErrorGroupError.Wrap(group)
+}
+
+// groupErrors holds the errors collected by an ErrorGroup once it is
+// finalized.
+type groupErrors struct {
+	errs   []error
+	excess int
+	counts []ClassCount
+}
+
+// Error returns the messages of all of the errors, one per line, or a summary
+// table if the errors were counted by class.
+func (g *groupErrors) Error() string {
+	if g.counts != nil {
+		return summarize(g.counts)
+	}
+	msgs := make([]string, 0, len(g.errs)+1)
+	for _, err := range g.errs {
+		msgs = append(msgs, err.Error())
+	}
+	if g.excess > 0 {
+		msgs = append(msgs, fmt.Sprintf("... and %d more.", g.excess))
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors, so that the standard library's errors.Is and
+// errors.As can find them.
+func (g *groupErrors) Unwrap() []error {
+	return g.errs
+}
+
+// assignExemplars fills in the exemplars of the group's class counts from its
+// errors, after the group has been decoded.
+func (g *groupErrors) assignExemplars() {
+	for _, err := range g.errs {
+		class := GetClass(err)
+		for i := range g.counts {
+			if g.counts[i].Class == class {
+				g.counts[i].Exemplars = append(g.counts[i].Exemplars, err)
+				break
+			}
+		}
+	}
+}
+
+// summarize renders a table of class counts.
+func summarize(counts []ClassCount) string {
+	total := 0
+	for _, count := range counts {
+		total += count.Count
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%d errors in %d classes:\n", total, len(counts))
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "CLASS\tCOUNT\tSAMPLE\n")
+	for _, count := range counts {
+		sample := ""
+		if len(count.Exemplars) > 0 {
+			sample = GetMessage(count.Exemplars[0])
+			if i := strings.IndexByte(sample, '\n'); i >= 0 {
+				sample = sample[:i]
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", count.Class.Path(), count.Count, sample)
+	}
+	w.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// GetGroupErrors returns the individual errors collected into err by
+// ErrorGroup.Finalize, or nil if err isn't a finalized group of errors.
+// Errors dropped by a bounded ErrorGroup are not included.
+func GetGroupErrors(err error) []error {
+	for err != nil {
+		if group, ok := err.(*groupErrors); ok {
+			return append([]error(nil), group.errs...)
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil
 }
 
 // LoggingErrorGroup is similar to ErrorGroup except that instead of collecting
 // all of the errors, it logs the errors immediately and just counts how many
-// non-nil errors have been seen. See the ErrorGroup example for usage.
+// non-nil errors have been seen, in total and by class. See the ErrorGroup
+// example for usage.
 type LoggingErrorGroup struct {
-	name   string
-	total  int
-	failed int
+	name     string
+	total    int
+	failed   int
+	counts   classCounter
+	burst    int
+	interval time.Duration
+	windows  map[*ErrorClass]*logWindow
+}
+
+// logWindow tracks how many errors of a class a rate limited
+// LoggingErrorGroup has logged and suppressed since start.
+type logWindow struct {
+	start      time.Time
+	logged     int
+	suppressed int
+	last       error
 }
 
 // NewLoggingErrorGroup returns a new LoggingErrorGroup with the given name.
@@ -131,26 +384,109 @@ func NewLoggingErrorGroup(name string) *LoggingErrorGroup {
 	return &LoggingErrorGroup{name: name}
 }
 
+// NewRateLimitedLoggingErrorGroup returns a new LoggingErrorGroup with the
+// given name that logs at most burst errors of each class (as determined by
+// GetClass) per interval. The number of errors that weren't logged is logged
+// once the interval is over and another error of that class is added, or on
+// Finalize. Suppressed errors are still counted.
+func NewRateLimitedLoggingErrorGroup(name string, burst int,
+	interval time.Duration) *LoggingErrorGroup {
+	return &LoggingErrorGroup{
+		name:     name,
+		burst:    burst,
+		interval: interval,
+		windows:  make(map[*ErrorClass]*logWindow),
+	}
+}
+
 // Add will handle a given error. If the error is non-nil, total and failed
-// are both incremented and the error is logged. If the error is nil, only
-// total is incremented.
+// are both incremented, the error's class is counted, and the error is logged
+// unless rate limited. If the error is nil, only total is incremented.
 func (e *LoggingErrorGroup) Add(err error) {
 	e.total++
 	if err != nil {
-		LogMethod("%s: %s", e.name, err)
+		e.counts.add(err, 0)
+		if e.allowLog(err) {
+			loggerFor(err).Log(ErrorLevel, e.name+": "+err.Error(),
+				Field{GroupField, e.name}, Field{ErrorField, err})
+		}
 		e.failed++
 	}
 }
 
+// allowLog returns whether err should be logged, given the rate limit.
+func (e *LoggingErrorGroup) allowLog(err error) bool {
+	if e.windows == nil {
+		return true
+	}
+	class := GetClass(err)
+	now := time.Now()
+	w := e.windows[class]
+	if w == nil {
+		w = &logWindow{start: now}
+		e.windows[class] = w
+	}
+	if now.Sub(w.start) >= e.interval {
+		e.logSuppressed(class, w)
+		*w = logWindow{start: now}
+	}
+	if w.logged < e.burst {
+		w.logged++
+		return true
+	}
+	w.suppressed++
+	w.last = err
+	return false
+}
+
+// logSuppressed logs how many errors of class weren't logged in w.
+func (e *LoggingErrorGroup) logSuppressed(class *ErrorClass, w *logWindow) {
+	if w.suppressed == 0 {
+		return
+	}
+	loggerFor(w.last).Log(ErrorLevel,
+		fmt.Sprintf("%s: %d more %s errors were not logged", e.name,
+			w.suppressed, class.Path()),
+		Field{GroupField, e.name}, Field{ErrorField, w.last})
+}
+
+// ClassCounts returns the number of failures of each class seen since the
+// last Finalize, most common class first.
+func (e *LoggingErrorGroup) ClassCounts() []ClassCount {
+	return e.counts.sorted()
+}
+
 // Finalize returns no error if no failures were observed, otherwise it will
-// return an ErrorGroupError with statistics about the observed errors.
+// return an ErrorGroupError with statistics about the observed errors,
+// including the number of failures of each class. GetClassCounts returns
+// those counts from the error.
 func (e *LoggingErrorGroup) Finalize() (err error) {
+	classes := make([]*ErrorClass, 0, len(e.windows))
+	for class := range e.windows {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		return classes[i].Path() < classes[j].Path()
+	})
+	for _, class := range classes {
+		e.logSuppressed(class, e.windows[class])
+		delete(e.windows, class)
+	}
 	if e.failed > 0 {
-		err = ErrorGroupError.New("%s: %d of %d failed.", e.name, e.failed,
-			e.total)
+		counts := e.counts.sorted()
+		breakdown := make([]string, 0, len(counts))
+		for _, count := range counts {
+			breakdown = append(breakdown,
+				fmt.Sprintf("%s (%d)", count.Class.Path(), count.Count))
+		}
+		err = ErrorGroupError.NewWith(
+			fmt.Sprintf("%s: %d of %d failed. By class: %s.", e.name,
+				e.failed, e.total, strings.Join(breakdown, ", ")),
+			SetData(classCountsKey, counts))
 	}
 	e.total = 0
 	e.failed = 0
+	e.counts = classCounter{}
 	return err
 }
 